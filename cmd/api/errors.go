@@ -1,17 +1,16 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"net/http"
-	"strings"
 
-	"example.com/pkg/response"
+	"example.com/pkg/database"
+	"example.com/pkg/server"
 )
 
-func (app *application) errorMessage(w http.ResponseWriter, r *http.Request, status int, message string, headers http.Header) {
-	message = strings.ToUpper(message[:1]) + message[1:]
-
-	err := response.JSONWithHeaders(w, status, map[string]string{"Error": message}, headers)
+func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, e server.APIError) {
+	err := server.WriteAPIError(w, e)
 	if err != nil {
 		app.logger.Error(err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -19,22 +18,27 @@ func (app *application) errorMessage(w http.ResponseWriter, r *http.Request, sta
 }
 
 func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Error(err)
-
-	message := "The server encountered a problem and could not process your request"
-	app.errorMessage(w, r, http.StatusInternalServerError, message, nil)
-}
-
-func (app *application) notFound(w http.ResponseWriter, r *http.Request) {
-	message := "The requested resource could not be found"
-	app.errorMessage(w, r, http.StatusNotFound, message, nil)
+	switch {
+	case errors.Is(err, database.ErrContextCanceled) && r.Context().Err() == context.Canceled:
+		// A query abandoned because the client went away isn't a server
+		// error.
+		app.logger.Info("request abandoned: %s", err)
+		app.errorResponse(w, r, server.InternalError())
+	case errors.Is(err, database.ErrContextCanceled):
+		// The query ran out of time while the client was still waiting, so
+		// the database is too slow to answer right now.
+		app.logger.Error(err)
+		app.errorResponse(w, r, server.ServiceUnavailable())
+	default:
+		app.logger.Error(err)
+		app.errorResponse(w, r, server.InternalError())
+	}
 }
 
-func (app *application) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
-	message := fmt.Sprintf("The %s method is not supported for this resource", r.Method)
-	app.errorMessage(w, r, http.StatusMethodNotAllowed, message, nil)
+func (app *application) serviceUnavailable(w http.ResponseWriter, r *http.Request) {
+	app.errorResponse(w, r, server.ServiceUnavailable())
 }
 
 func (app *application) badRequest(w http.ResponseWriter, r *http.Request, err error) {
-	app.errorMessage(w, r, http.StatusBadRequest, err.Error(), nil)
+	app.errorResponse(w, r, server.BadRequest(err.Error()))
 }
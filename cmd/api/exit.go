@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+
+	"example.com/pkg/leveledlog"
+)
+
+// exitError is returned by a command to choose the exit code main uses, one of
+// the leveledlog exit codes.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// exitCode returns the exit code for err, ExitGeneric unless err is or wraps
+// an exitError.
+func exitCode(err error) int {
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	return leveledlog.ExitGeneric
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"example.com/pkg/leveledlog"
+)
+
+func TestExitCode(t *testing.T) {
+	dbErr := &exitError{leveledlog.ExitDatabase, errors.New("open database: boom")}
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"plain", errors.New("boom"), leveledlog.ExitGeneric},
+		{"exit error", dbErr, leveledlog.ExitDatabase},
+		{"wrapped exit error", fmt.Errorf("run: %w", dbErr), leveledlog.ExitDatabase},
+	}
+
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("%s: got %d; want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
+	"example.com/pkg/database"
 	"example.com/pkg/response"
 )
 
@@ -16,3 +19,100 @@ func (app *application) status(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, r, err)
 	}
 }
+
+func (app *application) readiness(w http.ResponseWriter, r *http.Request) {
+	if app.drain.Draining() {
+		app.serviceUnavailable(w, r)
+		return
+	}
+
+	err := app.db.Ready(r.Context())
+	if err != nil {
+		// A prober that gave up waiting says nothing about the database, so
+		// it isn't worth a warning, but it still mustn't be told all is well.
+		if !errors.Is(err, database.ErrContextCanceled) || r.Context().Err() == nil {
+			app.logger.Warning("readiness check failed: %s", err)
+		}
+		app.serviceUnavailable(w, r)
+		return
+	}
+
+	data := map[string]string{
+		"Status": "OK",
+	}
+
+	err = response.JSON(w, http.StatusOK, data)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// drainServer fails readiness checks from now on so load balancers stop
+// routing traffic here, then shuts the server down once the drain period has
+// passed, serving requests until then.
+func (app *application) drainServer(w http.ResponseWriter, r *http.Request) {
+	app.drain.Start()
+	app.logger.Info("draining")
+
+	data := map[string]string{
+		"Status": "Draining",
+	}
+
+	err := response.JSON(w, http.StatusAccepted, data)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// indexStats reports the query planner's index estimates, to help spot unused
+// or redundant indexes.
+func (app *application) indexStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := app.db.IndexStats()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"Indexes": stats})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// sizeInfo reports the size of the database and how much of it is free pages,
+// to help decide when to vacuum.
+func (app *application) sizeInfo(w http.ResponseWriter, r *http.Request) {
+	info, err := app.db.SizeInfo()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, info)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// selfTest runs a write-read-delete round trip against the database and
+// reports how long it took.
+func (app *application) selfTest(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	err := app.db.SelfTest(r.Context())
+	if err != nil {
+		app.logger.Warning("self test failed: %s", err)
+		app.serviceUnavailable(w, r)
+		return
+	}
+
+	data := map[string]string{
+		"Status":   "OK",
+		"Duration": time.Since(start).String(),
+	}
+
+	err = response.JSON(w, http.StatusOK, data)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"example.com/pkg/database"
+	"example.com/pkg/leveledlog"
+	"example.com/pkg/server"
+)
+
+func newTestApplication(t *testing.T) *application {
+	t.Helper()
+
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return &application{
+		db:     db,
+		drain:  &server.Drain{},
+		logger: leveledlog.NewLogger(io.Discard, leveledlog.LevelAll, false),
+		urls:   server.NewRoutes(),
+	}
+}
+
+func TestReadinessContextCanceled(t *testing.T) {
+	app := newTestApplication(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req := httptest.NewRequest(http.MethodGet, "/ready", nil).WithContext(ctx)
+	rr := httptest.NewRecorder()
+	app.readiness(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d; want %d", rr.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestServerErrorContextCanceled(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name   string
+		cancel bool
+		want   int
+	}{
+		{name: "client gone", cancel: true, want: http.StatusInternalServerError},
+		{name: "query timed out", cancel: false, want: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+
+			queryCtx, queryCancel := context.WithCancel(context.Background())
+			queryCancel()
+			err := app.db.Ready(queryCtx)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+			rr := httptest.NewRecorder()
+			app.serverError(rr, req, err)
+
+			if rr.Code != tt.want {
+				t.Errorf("got status %d; want %d", rr.Code, tt.want)
+			}
+		})
+	}
+}
@@ -1,54 +1,52 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"os"
 
+	"example.com/pkg/config"
 	"example.com/pkg/database"
 	"example.com/pkg/leveledlog"
 	"example.com/pkg/server"
 )
 
-type config struct {
-	addr  string
-	env   string
-	dbDSN string
-}
-
 type application struct {
-	config config
+	config config.Config
 	db     *database.Sqlite
-	logger *leveledlog.Logger
+	drain  *server.Drain
+	logger leveledlog.Interface
+	urls   *server.Routes
 }
 
 func main() {
-	var cfg config
+	logger := leveledlog.NewStdLogger(leveledlog.LevelAll, true)
 
-	flag.StringVar(&cfg.addr, "addr", "localhost:4444", "server address to listen on")
-	flag.StringVar(&cfg.env, "env", "development", "operating environment: development, testing, staging or production")
-	flag.StringVar(&cfg.dbDSN, "dbdsn", "data/example.db", "sqlite3 DSN")
-	flag.Parse()
+	args := os.Args[1:]
 
-	logger := leveledlog.NewLogger(os.Stdout, leveledlog.LevelAll, true)
+	var command string
+	if len(args) > 0 && (args[0] == "schema" || args[0] == "migrate") {
+		command, args = args[0], args[1:]
+	}
 
-	db, err := database.New(cfg.dbDSN)
+	cfg, err := config.Load(args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
 	if err != nil {
-		logger.Fatal(err)
+		logger.FatalCode(leveledlog.ExitConfig, err)
 	}
-	defer db.Close()
 
-	app := &application{
-		config: cfg,
-		db:     db,
-		logger: logger,
+	switch command {
+	case "schema":
+		err = printSchema(cfg)
+	case "migrate":
+		err = migrate(cfg, logger)
+	default:
+		err = run(context.Background(), cfg, logger)
 	}
-
-	logger.Info("starting server on %s", cfg.addr)
-
-	err = server.Run(cfg.addr, app.routes())
 	if err != nil {
-		logger.Fatal(err)
+		logger.FatalCode(exitCode(err), err)
 	}
-
-	logger.Info("server stopped")
 }
@@ -1,25 +0,0 @@
-package main
-
-import (
-	"fmt"
-	"net/http"
-)
-
-func (app *application) recoverPanic(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				app.serverError(w, r, fmt.Errorf("%s", err))
-			}
-		}()
-
-		next.ServeHTTP(w, r)
-	})
-}
-
-func (app *application) logRequest(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.logger.Info("%s %s", r.Method, r.URL.RequestURI())
-		next.ServeHTTP(w, r)
-	})
-}
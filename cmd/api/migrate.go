@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+
+	"example.com/pkg/config"
+	"example.com/pkg/database"
+	"example.com/pkg/leveledlog"
+)
+
+// migrate implements the "api migrate" subcommand, which applies any pending
+// migrations and exits, for deployments that run migrations as a separate step
+// before starting the new version.
+func migrate(cfg config.Config, logger *leveledlog.Logger) error {
+	names, err := database.MigrationNames()
+	if err != nil {
+		return fmt.Errorf("list migrations: %w", err)
+	}
+
+	db, err := database.New(cfg.DBDSN,
+		database.WithLogger(logger),
+		database.WithMigrationMode(database.MigrationAuto),
+	)
+	if err != nil {
+		return &exitError{leveledlog.ExitDatabase, fmt.Errorf("migrate database: %w", err)}
+	}
+
+	logger.WithFields(leveledlog.Fields{"migrations": len(names)}).Info("database is up to date")
+	return db.Close()
+}
@@ -2,20 +2,45 @@ package main
 
 import (
 	"net/http"
+	"strings"
 
+	"example.com/pkg/server"
 	"github.com/alexedwards/flow"
 )
 
 func (app *application) routes() http.Handler {
 	mux := flow.New()
 
-	mux.NotFound = http.HandlerFunc(app.notFound)
-	mux.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowed)
+	mux.NotFound = http.HandlerFunc(server.NotFoundHandler)
+	mux.MethodNotAllowed = http.HandlerFunc(server.MethodNotAllowedHandler)
 
-	mux.Use(app.recoverPanic)
-	mux.Use(app.logRequest)
+	mux.Use(server.RequestID)
+	// Health checks are polled every few seconds, so only log them when they
+	// fail.
+	skipPaths := strings.FieldsFunc(app.config.LogSkipPaths, func(r rune) bool { return r == ',' })
+	mux.Use(server.RequestLogger(app.logger,
+		server.WithSkipPaths(skipPaths...),
+		server.WithQueryWarningThreshold(50),
+		server.WithRoutes(app.urls),
+	))
+	mux.Use(server.Recoverer(app.logger))
+	mux.Use(server.RequireContentType("application/json"))
 
-	mux.HandleFunc("/status", app.status, "GET")
+	mux.HandleFunc(app.urls.Route("status", "/status"), app.status, "GET")
+	mux.HandleFunc(app.urls.Route("readiness", "/readyz"), app.readiness, "GET")
+
+	// The operational endpoints are only registered when an admin token is
+	// configured, and require it.
+	if app.config.AdminToken != "" {
+		mux.Group(func(mux *flow.Mux) {
+			mux.Use(server.RequireBearerToken(app.config.AdminToken))
+
+			mux.HandleFunc(app.urls.Route("drain", "/admin/drain"), app.drainServer, "POST")
+			mux.HandleFunc(app.urls.Route("indexes", "/admin/indexes"), app.indexStats, "GET")
+			mux.HandleFunc(app.urls.Route("size", "/admin/size"), app.sizeInfo, "GET")
+			mux.HandleFunc(app.urls.Route("selftest", "/debug/selftest"), app.selfTest, "POST")
+		})
+	}
 
 	return mux
 }
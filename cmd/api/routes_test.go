@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example.com/pkg/server"
+)
+
+func TestAdminRoutesRequireToken(t *testing.T) {
+	// The operational endpoints guarded by the admin token, with the status
+	// each responds with when authorized.
+	routes := []struct {
+		method string
+		path   string
+		ok     int
+	}{
+		{http.MethodPost, "/admin/drain", http.StatusAccepted},
+		{http.MethodGet, "/admin/indexes", http.StatusOK},
+		{http.MethodGet, "/admin/size", http.StatusOK},
+		{http.MethodPost, "/debug/selftest", http.StatusOK},
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		header     string
+		authorized bool
+		want       int
+	}{
+		{"disabled", "", "Bearer s3cret", false, http.StatusNotFound},
+		{"missing token", "s3cret", "", false, http.StatusUnauthorized},
+		{"wrong token", "s3cret", "Bearer nope", false, http.StatusUnauthorized},
+		{"valid token", "s3cret", "Bearer s3cret", true, 0},
+	}
+
+	for _, route := range routes {
+		for _, tt := range tests {
+			app := newTestApplication(t)
+			app.config.AdminToken = tt.token
+
+			req := httptest.NewRequest(route.method, route.path, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rr := httptest.NewRecorder()
+			app.routes().ServeHTTP(rr, req)
+
+			want := tt.want
+			if tt.authorized {
+				want = route.ok
+			}
+			if rr.Code != want {
+				t.Errorf("%s %s, %s: got status %d; want %d", route.method, route.path, tt.name, rr.Code, want)
+			}
+
+			wantDraining := tt.authorized && route.path == "/admin/drain"
+			if draining := app.drain.Draining(); draining != wantDraining {
+				t.Errorf("%s %s, %s: got draining %t; want %t", route.method, route.path, tt.name, draining, wantDraining)
+			}
+		}
+	}
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		drain  bool
+		limit  bool
+		status int
+		code   string
+	}{
+		{"unknown route", http.MethodGet, "/missing", false, false, http.StatusNotFound, "not_found"},
+		{"wrong method", http.MethodPost, "/status", false, false, http.StatusMethodNotAllowed, "method_not_allowed"},
+		{"draining", http.MethodGet, "/readyz", true, false, http.StatusServiceUnavailable, "service_unavailable"},
+		{"rate limited", http.MethodGet, "/status", false, true, http.StatusTooManyRequests, "rate_limited"},
+	}
+
+	for _, tt := range tests {
+		app := newTestApplication(t)
+		if tt.drain {
+			app.drain.Start()
+		}
+
+		handler := app.routes()
+		if tt.limit {
+			// A limiter with no burst rejects every request.
+			handler = server.NewRateLimiter(1, 0, nil).Middleware(handler)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if rr.Code != tt.status {
+			t.Errorf("%s: got status %d; want %d", tt.name, rr.Code, tt.status)
+		}
+
+		var body struct {
+			Error server.APIError `json:"error"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Errorf("%s: got body %q: %s", tt.name, rr.Body.String(), err)
+			continue
+		}
+		if body.Error.Code != tt.code || body.Error.Message == "" {
+			t.Errorf("%s: got error %+v; want code %s with a message", tt.name, body.Error, tt.code)
+		}
+	}
+}
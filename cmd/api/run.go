@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"example.com/pkg/config"
+	"example.com/pkg/database"
+	"example.com/pkg/leveledlog"
+	"example.com/pkg/runtimestats"
+	"example.com/pkg/server"
+)
+
+// migrationMode returns the database migration mode for cfg. Production runs
+// migrations as a separate deploy step with "api migrate", so by default it
+// only checks that none are pending.
+func migrationMode(cfg config.Config) database.MigrationMode {
+	switch cfg.DBMigrations {
+	case "check":
+		return database.MigrationCheck
+	case "skip":
+		return database.MigrationSkip
+	case "auto":
+		return database.MigrationAuto
+	}
+
+	if cfg.Env == "production" {
+		return database.MigrationCheck
+	}
+	return database.MigrationAuto
+}
+
+// run opens and migrates the database, serves the API until a shutdown signal
+// is received or ctx is cancelled, then closes the database.
+func run(ctx context.Context, cfg config.Config, logger *leveledlog.Logger) error {
+	// Log the resolved configuration as a single entry to help debug deploys,
+	// before anything that can fail so it is there when startup does.
+	// Secret-like values are redacted by the logger.
+	fields := cfg.Fields()
+	fields["dbdsn"] = database.RedactDSN(cfg.DBDSN)
+	logger.WithFields(fields).Info("effective configuration")
+
+	logger.Info("opening database")
+
+	// The database may live on a volume that is mounted shortly after the
+	// process starts, so opening it can optionally be retried.
+	var db *database.Sqlite
+	err := server.Retry(leveledlog.NewContext(ctx, logger), cfg.DBOpenAttempts, cfg.DBOpenBackoff, func() error {
+		var err error
+		db, err = database.New(cfg.DBDSN,
+			database.WithLogger(logger),
+			database.WithIntegrityCheck(),
+			database.WithSlowMigrationThreshold(10*time.Second),
+			database.WithMigrationMode(migrationMode(cfg)),
+		)
+		return err
+	})
+	if err != nil {
+		return &exitError{leveledlog.ExitDatabase, fmt.Errorf("open database: %w", err)}
+	}
+	defer func() {
+		logger.Info("closing database")
+		db.Close()
+	}()
+
+	logger.WithFields(leveledlog.Fields{
+		"dbmaxopenconns": db.DB().Stats().MaxOpenConnections,
+	}).Info("database opened")
+
+	if cfg.DBWarmup > 0 {
+		err = db.Warmup(cfg.DBWarmup)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.DBCheckpoint > 0 {
+		db.StartCheckpointer(cfg.DBCheckpoint)
+	}
+
+	if cfg.RuntimeStats > 0 {
+		statsCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		runtimestats.Start(statsCtx, logger, cfg.RuntimeStats)
+	}
+
+	app := &application{
+		config: cfg,
+		db:     db,
+		drain:  &server.Drain{},
+		logger: logger,
+		urls:   server.NewRoutes(),
+	}
+
+	srvCfg := server.Config{
+		Addr:         cfg.Addr,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+		ShutdownTimeouts: map[os.Signal]time.Duration{
+			syscall.SIGTERM: cfg.ShutdownTimeout,
+			syscall.SIGINT:  server.DefaultShutdownTimeouts()[syscall.SIGINT],
+		},
+		Drain:           app.drain,
+		DrainPeriod:     cfg.Drain,
+		ReadyFile:       cfg.ReadyFile,
+		GracefulRestart: cfg.GracefulRestart,
+		Logger:          logger,
+	}
+
+	ln, ok, err := server.ListenerFromSystemd()
+	if err != nil {
+		return err
+	}
+	if ok {
+		srvCfg.Listener = ln
+	}
+
+	err = server.RunContext(ctx, srvCfg, app.routes())
+	if err != nil {
+		return fmt.Errorf("run server: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+
+	"example.com/pkg/config"
+	"example.com/pkg/database"
+)
+
+// printSchema implements the "api schema" subcommand, which prints the live
+// database schema. The database is opened read-only so no migrations are run,
+// and the schema is headed with the latest embedded migration so it can be
+// told apart from a database that is behind the binary.
+func printSchema(cfg config.Config) error {
+	names, err := database.MigrationNames()
+	if err != nil {
+		return fmt.Errorf("list migrations: %w", err)
+	}
+
+	db, err := database.New(cfg.DBDSN, database.WithReadOnly())
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+
+	schema, err := db.Schema()
+	if err != nil {
+		return err
+	}
+
+	if len(names) > 0 {
+		fmt.Printf("-- latest embedded migration: %s\n", names[len(names)-1])
+	}
+	fmt.Print(schema)
+	return nil
+}
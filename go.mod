@@ -6,6 +6,7 @@ require (
 	github.com/alexedwards/flow v0.0.0-20220421161004-151985479ec9
 	github.com/jmoiron/sqlx v1.3.5
 	github.com/mattn/go-sqlite3 v1.14.10
+	golang.org/x/sync v0.1.0
 )
 
 require github.com/lib/pq v1.10.0 // indirect
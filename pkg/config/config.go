@@ -0,0 +1,263 @@
+// Package config loads the service configuration from defaults, a JSON file,
+// environment variables and command-line flags, in increasing order of
+// precedence.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is prepended to the upper-cased flag name to give the environment
+// variable for each setting, e.g. -readtimeout is read from API_READTIMEOUT.
+const EnvPrefix = "API_"
+
+type Config struct {
+	Addr     string
+	Env      string
+	DBDSN    string
+	DBWarmup int
+
+	DBOpenAttempts int
+	DBOpenBackoff  time.Duration
+	DBCheckpoint   time.Duration
+
+	// DBMigrations is what to do about pending migrations at startup: auto,
+	// check or skip. Empty means check in production and auto elsewhere.
+	DBMigrations string
+
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+	Drain           time.Duration
+
+	RuntimeStats time.Duration
+
+	// LogSkipPaths is a comma-separated list of request paths that are only
+	// logged when they fail.
+	LogSkipPaths string
+
+	// ReadyFile is the path of a file created once the server is serving and
+	// removed on shutdown, for file-based readiness probes. Empty disables
+	// it.
+	ReadyFile string
+
+	// GracefulRestart makes the server re-execute itself on SIGUSR2, handing
+	// over its listening socket, see server.Config.GracefulRestart.
+	GracefulRestart bool
+
+	// AdminToken is the bearer token required by the /admin and /debug
+	// endpoints. Empty disables them.
+	AdminToken string
+}
+
+// Load parses args (normally os.Args[1:]) into a Config. Values are resolved
+// from, in increasing order of precedence: the defaults, the JSON file named
+// by the -config flag, API_* environment variables and the flags themselves.
+//
+// Durations are written as Go duration strings such as "30s" or "1m30s" in
+// every source. An invalid value produces an error naming the setting and
+// where the value came from.
+func Load(args []string) (Config, error) {
+	var cfg Config
+	var file string
+
+	fs := flag.NewFlagSet("api", flag.ContinueOnError)
+
+	fs.StringVar(&file, "config", "", "path to a JSON configuration file")
+	fs.StringVar(&cfg.Addr, "addr", "localhost:4444", "server address to listen on")
+	fs.StringVar(&cfg.Env, "env", "development", "operating environment: development, testing, staging or production")
+	fs.StringVar(&cfg.DBDSN, "dbdsn", "data/example.db", "sqlite3 DSN")
+	fs.IntVar(&cfg.DBWarmup, "dbwarmup", 0, "number of database connections to open at startup")
+	fs.IntVar(&cfg.DBOpenAttempts, "dbopenattempts", 1, "number of attempts to open the database before giving up")
+	fs.DurationVar(&cfg.DBOpenBackoff, "dbopenbackoff", time.Second, "wait before retrying to open the database, doubled after each attempt")
+	fs.DurationVar(&cfg.DBCheckpoint, "dbcheckpoint", 0, "interval for checkpointing the WAL when litestream isn't managing it (0 disables)")
+	fs.StringVar(&cfg.DBMigrations, "dbmigrations", "", "pending migrations at startup: auto applies them, check fails, skip ignores them (default check in production, else auto)")
+	fs.DurationVar(&cfg.ReadTimeout, "readtimeout", 10*time.Second, "maximum duration for reading a request")
+	fs.DurationVar(&cfg.WriteTimeout, "writetimeout", 30*time.Second, "maximum duration for writing a response")
+	fs.DurationVar(&cfg.IdleTimeout, "idletimeout", time.Minute, "maximum time to keep idle keep-alive connections open")
+	fs.DurationVar(&cfg.ShutdownTimeout, "shutdowntimeout", 20*time.Second, "time allowed for in-flight requests to complete on SIGTERM")
+	fs.DurationVar(&cfg.Drain, "drain", 0, "how long to keep serving with failing readiness checks before shutting down")
+
+	fs.DurationVar(&cfg.RuntimeStats, "runtimestats", 0, "interval for logging runtime statistics (0 disables)")
+	fs.StringVar(&cfg.ReadyFile, "readyfile", "", "file to create once serving and remove on shutdown, for file-based readiness probes")
+	fs.BoolVar(&cfg.GracefulRestart, "gracefulrestart", false, "restart without dropping connections on SIGUSR2")
+	fs.StringVar(&cfg.AdminToken, "admintoken", "", "bearer token required by the /admin and /debug endpoints (empty disables them)")
+	fs.StringVar(&cfg.LogSkipPaths, "logskippaths", "/status,/readyz", "comma-separated request paths that are only logged on failure")
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	if file != "" {
+		values, err := readFile(file)
+		if err != nil {
+			return Config{}, err
+		}
+		for name, value := range values {
+			if name == "config" || fs.Lookup(name) == nil {
+				return Config{}, fmt.Errorf("config file %s: unknown setting %q", file, name)
+			}
+			if explicit[name] || envSet(name) {
+				continue
+			}
+			if err := fs.Set(name, value); err != nil {
+				return Config{}, fmt.Errorf("config file %s: invalid value %q for %s: %w", file, value, name, err)
+			}
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || explicit[f.Name] || f.Name == "config" {
+			return
+		}
+		if value, ok := os.LookupEnv(envName(f.Name)); ok {
+			if setErr := fs.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("environment %s: invalid value %q: %w", envName(f.Name), value, setErr)
+			}
+		}
+	})
+	if err != nil {
+		return Config{}, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Fields returns the configuration keyed by setting name, for logging. Values
+// are not redacted here; that is left to the logger.
+func (cfg Config) Fields() map[string]any {
+	return map[string]any{
+		"addr":            cfg.Addr,
+		"env":             cfg.Env,
+		"dbdsn":           cfg.DBDSN,
+		"dbwarmup":        cfg.DBWarmup,
+		"dbopenattempts":  cfg.DBOpenAttempts,
+		"dbopenbackoff":   cfg.DBOpenBackoff.String(),
+		"dbcheckpoint":    cfg.DBCheckpoint.String(),
+		"dbmigrations":    cfg.DBMigrations,
+		"readtimeout":     cfg.ReadTimeout.String(),
+		"writetimeout":    cfg.WriteTimeout.String(),
+		"idletimeout":     cfg.IdleTimeout.String(),
+		"shutdowntimeout": cfg.ShutdownTimeout.String(),
+		"drain":           cfg.Drain.String(),
+		"runtimestats":    cfg.RuntimeStats.String(),
+		"logskippaths":    cfg.LogSkipPaths,
+		"readyfile":       cfg.ReadyFile,
+		"gracefulrestart": cfg.GracefulRestart,
+		"admintoken":      cfg.AdminToken,
+	}
+}
+
+// Validate checks that the configuration values are usable.
+func (cfg Config) Validate() error {
+	var problems []string
+
+	if cfg.Addr == "" {
+		problems = append(problems, "addr must be set")
+	}
+
+	switch cfg.Env {
+	case "development", "testing", "staging", "production":
+	default:
+		problems = append(problems, fmt.Sprintf("env %q must be one of development, testing, staging or production", cfg.Env))
+	}
+
+	if cfg.DBWarmup < 0 {
+		problems = append(problems, "dbwarmup must not be negative")
+	}
+
+	if cfg.DBOpenAttempts < 1 {
+		problems = append(problems, "dbopenattempts must be at least 1")
+	}
+
+	if cfg.DBOpenBackoff < 0 {
+		problems = append(problems, "dbopenbackoff must not be negative")
+	}
+
+	if cfg.DBCheckpoint < 0 {
+		problems = append(problems, "dbcheckpoint must not be negative")
+	}
+
+	switch cfg.DBMigrations {
+	case "", "auto", "check", "skip":
+	default:
+		problems = append(problems, fmt.Sprintf("dbmigrations %q must be one of auto, check or skip", cfg.DBMigrations))
+	}
+
+	timeouts := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"readtimeout", cfg.ReadTimeout},
+		{"writetimeout", cfg.WriteTimeout},
+		{"idletimeout", cfg.IdleTimeout},
+		{"shutdowntimeout", cfg.ShutdownTimeout},
+	}
+	for _, t := range timeouts {
+		if t.value <= 0 {
+			problems = append(problems, fmt.Sprintf("%s must be greater than zero", t.name))
+		}
+	}
+
+	if cfg.Drain < 0 {
+		problems = append(problems, "drain must not be negative")
+	}
+
+	if cfg.RuntimeStats < 0 {
+		problems = append(problems, "runtimestats must not be negative")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func envName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(flagName)
+}
+
+func envSet(flagName string) bool {
+	_, ok := os.LookupEnv(envName(flagName))
+	return ok
+}
+
+// readFile reads a JSON object of settings keyed by flag name. Values may be
+// strings or numbers, e.g. {"addr": ":8080", "readtimeout": "5s"}.
+func readFile(path string) (map[string]string, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+
+	var raw map[string]any
+	if err := json.Unmarshal(buf, &raw); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for name, value := range raw {
+		switch v := value.(type) {
+		case string:
+			values[name] = v
+		case float64, bool:
+			values[name] = fmt.Sprint(v)
+		default:
+			return nil, fmt.Errorf("config file %s: %s must be a string or number", path, name)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// backupStepPause is how long OnlineBackup waits between steps, giving writers
+// a chance to take the write lock.
+const backupStepPause = 10 * time.Millisecond
+
+// OnlineBackup copies the database to a new file at destPath using SQLite's
+// online backup API. Pages are copied pagesPerStep at a time, pausing between
+// steps, so the source stays writable throughout; unlike VACUUM INTO this
+// suits multi-gigabyte databases. If the source is written to during the
+// backup, SQLite restarts the copy so the result is always consistent.
+//
+// progress, if not nil, is called after each step with the number of pages
+// remaining and the total. The backup stops with ctx's error if ctx is done
+// before it completes, leaving destPath incomplete.
+func (db *Sqlite) OnlineBackup(ctx context.Context, destPath string, pagesPerStep int, progress func(remaining, total int)) error {
+	if pagesPerStep <= 0 {
+		pagesPerStep = 100
+	}
+
+	dest, err := sql.Open("sqlite3", destPath)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("open backup destination: %w", err)
+	}
+	defer destConn.Close()
+
+	srcConn, err := db.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return destConn.Raw(func(destDriverConn any) error {
+		return srcConn.Raw(func(srcDriverConn any) error {
+			destSQLite, ok1 := destDriverConn.(*sqlite3.SQLiteConn)
+			srcSQLite, ok2 := srcDriverConn.(*sqlite3.SQLiteConn)
+			if !ok1 || !ok2 {
+				return errors.New("database: online backup requires the sqlite3 driver")
+			}
+
+			backup, err := destSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return err
+			}
+			defer backup.Close()
+
+			for {
+				done, err := backup.Step(pagesPerStep)
+				if err != nil {
+					return err
+				}
+				if progress != nil {
+					progress(backup.Remaining(), backup.PageCount())
+				}
+				if done {
+					break
+				}
+
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(backupStepPause):
+				}
+			}
+
+			return backup.Finish()
+		})
+	})
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// queryCache is a size-bounded LRU cache of scanned query results.
+type queryCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	value   reflect.Value
+	expires time.Time
+}
+
+func newQueryCache(size int) *queryCache {
+	return &queryCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *queryCache) get(key string) (reflect.Value, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return reflect.Value{}, false
+	}
+
+	entry := el.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return reflect.Value{}, false
+	}
+
+	c.order.MoveToFront(el)
+	return entry.value, true
+}
+
+func (c *queryCache) set(key string, value reflect.Value, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, value: value, expires: time.Now().Add(ttl)})
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *queryCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+}
+
+// CachedGet is a read-through cache in front of GetContext, for hot lookups of
+// data that rarely changes. On a miss it runs the query, scans the result into
+// dest and keeps a copy under key for ttl; until then, calls with the same key
+// copy the cached result into dest without querying. If dest points to a
+// slice, the query is run with SelectContext instead.
+//
+// Concurrent misses for the same key are coalesced into a single query whose
+// result is shared by all the callers, so an expiring hot key doesn't send a
+// stampede of identical queries to the database. The query runs with the
+// context of the first caller; if it fails, including because that context
+// was cancelled, every waiting caller gets the error and nothing is cached, so
+// the next call queries again.
+//
+// The copy is shallow, so slices and maps in a cached result are shared
+// between callers and must not be modified. Errors, including ErrNotFound,
+// aren't cached. Caching requires WithQueryCache; without it only concurrent
+// calls share a query. Calls within a transaction from ContextWithTx bypass
+// the cache and always query.
+func (db *Sqlite) CachedGet(ctx context.Context, key string, ttl time.Duration, dest any, query string, args ...any) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("database: CachedGet dest must be a non-nil pointer, got %T", dest)
+	}
+	typ := v.Elem().Type()
+
+	load := func(dest any) error {
+		if typ.Kind() == reflect.Slice && typ.Elem().Kind() != reflect.Uint8 {
+			return db.SelectContext(ctx, dest, query, args...)
+		}
+		return db.GetContext(ctx, dest, query, args...)
+	}
+
+	// A transaction sees its own uncommitted writes, so its results can't be
+	// shared.
+	if _, ok := TxFromContext(ctx); ok {
+		return load(dest)
+	}
+
+	if db.cache != nil {
+		if cached, ok := db.cache.get(key); ok && cached.Type() == typ {
+			v.Elem().Set(cached)
+			return nil
+		}
+	}
+
+	result, err, _ := db.flight.Do(key, func() (any, error) {
+		value := reflect.New(typ)
+		if err := load(value.Interface()); err != nil {
+			return nil, err
+		}
+
+		if db.cache != nil {
+			db.cache.set(key, value.Elem(), ttl)
+		}
+		return value.Elem(), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Callers sharing a key are expected to share a type, but fall back to
+	// querying directly rather than panicking if they don't.
+	shared := result.(reflect.Value)
+	if shared.Type() != typ {
+		return load(dest)
+	}
+
+	v.Elem().Set(shared)
+	return nil
+}
+
+// InvalidateCache removes the result cached by CachedGet under key, so the
+// next call runs the query. It should be called after writing data that the
+// cached query reads.
+func (db *Sqlite) InvalidateCache(key string) {
+	if db.cache != nil {
+		db.cache.remove(key)
+	}
+}
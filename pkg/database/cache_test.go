@@ -0,0 +1,39 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCachedGetSharedTypeMismatch(t *testing.T) {
+	db := newTestDB(t)
+
+	// Hold a flight for the key open with a result of a different type, so
+	// CachedGet joins it and has to fall back to querying itself.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go db.flight.Do("names", func() (any, error) {
+		close(started)
+		<-release
+		return reflect.ValueOf(0), nil
+	})
+	<-started
+
+	var names []string
+	done := make(chan error)
+	go func() {
+		done <- db.CachedGet(context.Background(), "names", time.Minute, &names, `SELECT 'a' UNION ALL SELECT 'b'`)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v; want %v", names, want)
+	}
+}
@@ -0,0 +1,51 @@
+package database
+
+import "time"
+
+// StartCheckpointer runs PRAGMA wal_checkpoint(TRUNCATE) every interval in a
+// background goroutine, which keeps the WAL file bounded in deployments where
+// litestream isn't managing checkpoints. It stops when the database is closed.
+//
+// Only the first call starts a checkpointer; later calls do nothing. A
+// non-positive interval disables checkpointing, and doesn't count as the first
+// call.
+func (db *Sqlite) StartCheckpointer(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	db.checkpointerOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-db.ctx.Done():
+					return
+				case <-ticker.C:
+					db.checkpoint()
+				}
+			}
+		}()
+	})
+}
+
+func (db *Sqlite) checkpoint() {
+	var result struct {
+		Busy         int `db:"busy"`
+		Log          int `db:"log"`
+		Checkpointed int `db:"checkpointed"`
+	}
+
+	if err := db.db.GetContext(db.ctx, &result, `PRAGMA wal_checkpoint(TRUNCATE);`); err != nil {
+		// Closing the database cancels the context mid-checkpoint.
+		if db.ctx.Err() != nil {
+			return
+		}
+		db.logger.Warning("wal checkpoint failed: %s", err)
+		return
+	}
+
+	db.logger.Debug("wal checkpoint: busy=%d log=%d checkpointed=%d", result.Busy, result.Log, result.Checkpointed)
+}
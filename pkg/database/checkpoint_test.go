@@ -0,0 +1,25 @@
+package database
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestStartCheckpointer(t *testing.T) {
+	db := newTestDB(t)
+
+	before := runtime.NumGoroutine()
+
+	db.StartCheckpointer(0)
+	db.StartCheckpointer(-time.Second)
+	if after := runtime.NumGoroutine(); after != before {
+		t.Errorf("non-positive interval: got %d goroutines; want %d", after, before)
+	}
+
+	db.StartCheckpointer(time.Hour)
+	db.StartCheckpointer(time.Hour)
+	if after := runtime.NumGoroutine(); after != before+1 {
+		t.Errorf("started twice: got %d goroutines; want %d", after, before+1)
+	}
+}
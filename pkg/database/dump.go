@@ -0,0 +1,203 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type schemaObject struct {
+	Type string `db:"type"`
+	Name string `db:"name"`
+	SQL  string `db:"sql"`
+}
+
+// Dump writes the schema and contents of the database to w as a SQL script of
+// CREATE and INSERT statements, which Restore can load into another database,
+// including one created by a different SQLite version. The migrations table is
+// included, so a restored database doesn't rerun its migrations, as are the
+// AUTOINCREMENT counters in sqlite_sequence, so a restored table doesn't reuse
+// the IDs of rows deleted before the dump.
+//
+// The dump is read within a single read transaction, so it is consistent
+// while writers carry on. Rows are written as they are read, so the dump isn't
+// held in memory. Virtual tables, such as FTS indexes, aren't supported.
+func (db *Sqlite) Dump(ctx context.Context, w io.Writer) error {
+	tx, err := db.db.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return mapError(err)
+	}
+	defer tx.Rollback()
+
+	var objects []schemaObject
+	err = tx.SelectContext(ctx, &objects, `
+		SELECT type, name, sql FROM sqlite_master
+		WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+		ORDER BY type != 'table', rowid`)
+	if err != nil {
+		return mapError(err)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "-- SQLite dump written %s\n", time.Now().UTC().Format(time.RFC3339))
+
+	for _, obj := range objects {
+		if obj.Type == "table" && strings.HasPrefix(strings.ToUpper(obj.SQL), "CREATE VIRTUAL TABLE") {
+			return fmt.Errorf("database: dump: virtual table %q is not supported", obj.Name)
+		}
+
+		fmt.Fprintf(bw, "%s;\n", obj.SQL)
+
+		if obj.Type == "table" {
+			if err := dumpRows(ctx, tx, bw, obj.Name); err != nil {
+				return fmt.Errorf("database: dump %s: %w", obj.Name, err)
+			}
+		}
+	}
+
+	// SQLite creates sqlite_sequence along with the first AUTOINCREMENT table
+	// and fills it in as the rows above are inserted, which leaves out the
+	// IDs of deleted rows, so the counters are replaced once the tables are
+	// loaded.
+	var sequences int
+	err = tx.GetContext(ctx, &sequences, `SELECT COUNT(*) FROM sqlite_master WHERE name = 'sqlite_sequence'`)
+	if err != nil {
+		return mapError(err)
+	}
+	if sequences > 0 {
+		bw.WriteString("DELETE FROM sqlite_sequence;\n")
+		if err := dumpRows(ctx, tx, bw, "sqlite_sequence"); err != nil {
+			return fmt.Errorf("database: dump sqlite_sequence: %w", err)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// dumpRows writes an INSERT statement for each row of table. SQLite's quote()
+// formats each value as a literal, so values round-trip exactly.
+func dumpRows(ctx context.Context, tx *sqlx.Tx, w *bufio.Writer, table string) error {
+	var columns []string
+	if err := tx.SelectContext(ctx, &columns, `SELECT name FROM pragma_table_info(?)`, table); err != nil {
+		return mapError(err)
+	}
+
+	names := make([]string, len(columns))
+	values := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = quoteIdentifier(column)
+		values[i] = "quote(" + names[i] + ")"
+	}
+
+	prefix := "INSERT INTO " + quoteIdentifier(table) + " (" + strings.Join(names, ", ") + ") VALUES ("
+	query := "SELECT " + strings.Join(values, " || ', ' || ") + " FROM " + quoteIdentifier(table)
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return mapError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row string
+		if err := rows.Scan(&row); err != nil {
+			return err
+		}
+		w.WriteString(prefix)
+		w.WriteString(row)
+		w.WriteString(");\n")
+	}
+
+	return mapError(rows.Err())
+}
+
+// Restore runs a SQL script written by Dump within a single transaction,
+// replacing the schema and contents of the database: every existing table,
+// view and trigger is dropped first, including those created by New's
+// migrations, and if any statement fails nothing is changed. The script is
+// read and executed a statement at a time.
+//
+// Foreign keys are only checked when the transaction commits, so the order of
+// the tables in the script doesn't matter.
+func (db *Sqlite) Restore(ctx context.Context, r io.Reader) error {
+	return db.WithTx(ctx, func(tx *Tx) error {
+		if _, err := tx.ExecContext(ctx, `PRAGMA defer_foreign_keys = ON;`); err != nil {
+			return err
+		}
+
+		if err := dropSchema(ctx, tx); err != nil {
+			return err
+		}
+
+		br := bufio.NewReader(r)
+
+		var pending string
+		n := 0
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				return err
+			}
+			pending += line
+			eof := err != nil
+
+			// A statement can only end on a line ending in a semicolon, so
+			// only try to split then.
+			if !eof && !strings.HasSuffix(strings.TrimSpace(line), ";") {
+				continue
+			}
+
+			statements, rest := splitComplete(pending)
+			if eof && rest != "" {
+				statements = append(statements, rest)
+			}
+			pending = rest
+
+			for _, stmt := range statements {
+				n++
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("statement %d: %w", n, err)
+				}
+			}
+
+			if eof {
+				return nil
+			}
+		}
+	})
+}
+
+// dropSchema drops every table and view in the database. Indexes and triggers
+// are dropped along with their tables.
+func dropSchema(ctx context.Context, tx *Tx) error {
+	var objects []schemaObject
+	err := tx.SelectContext(ctx, &objects, `
+		SELECT type, name, '' AS sql FROM sqlite_master
+		WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		return mapError(err)
+	}
+
+	for _, obj := range objects {
+		stmt := "DROP TABLE " + quoteIdentifier(obj.Name)
+		if obj.Type == "view" {
+			stmt = "DROP VIEW " + quoteIdentifier(obj.Name)
+		}
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// quoteIdentifier quotes name for use as a table or column name.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestDumpRestoresAutoincrement(t *testing.T) {
+	ctx := context.Background()
+
+	src := newTestDB(t)
+	if _, err := src.db.Exec(`CREATE TABLE widgets (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := src.db.Exec(`INSERT INTO widgets (name) VALUES (?)`, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The counter stays at 3 once the newest row is deleted.
+	if _, err := src.db.Exec(`DELETE FROM widgets WHERE id = 3`); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := src.Dump(ctx, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := newTestDB(t)
+	if err := dst.Restore(ctx, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := dst.db.Exec(`INSERT INTO widgets (name) VALUES ('d')`); err != nil {
+		t.Fatal(err)
+	}
+	var id int
+	if err := dst.db.Get(&id, `SELECT id FROM widgets WHERE name = 'd'`); err != nil {
+		t.Fatal(err)
+	}
+	if id != 4 {
+		t.Errorf("got id %d; want 4", id)
+	}
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	// ErrNotFound is returned when a query that expects a row returns none.
+	ErrNotFound = errors.New("database: not found")
+
+	// ErrConflict is returned when a write violates a unique or primary key
+	// constraint.
+	ErrConflict = errors.New("database: conflict")
+
+	// ErrReadOnly is returned when a write is attempted on a database opened
+	// with WithReadOnly.
+	ErrReadOnly = errors.New("database: read only")
+
+	// ErrStorageFull is returned when a write fails because the disk is full or
+	// the database file can't be written to.
+	ErrStorageFull = errors.New("database: storage full")
+
+	// ErrContextCanceled is returned when a query is abandoned because its
+	// context was cancelled or its deadline passed, e.g. because the client
+	// disconnected. It isn't a database failure. The context error can still
+	// be tested for with errors.Is.
+	ErrContextCanceled = errors.New("database: context canceled")
+)
+
+// sentinelError annotates a driver error with one of the package sentinels so
+// callers can test for it with errors.Is while still unwrapping to the
+// original error.
+type sentinelError struct {
+	sentinel error
+	err      error
+}
+
+func (e *sentinelError) Error() string {
+	return e.sentinel.Error() + ": " + e.err.Error()
+}
+
+func (e *sentinelError) Unwrap() error {
+	return e.err
+}
+
+func (e *sentinelError) Is(target error) bool {
+	return target == e.sentinel
+}
+
+// mapError translates driver errors into the package sentinel errors.
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return &sentinelError{ErrNotFound, err}
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return &sentinelError{ErrContextCanceled, err}
+	}
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.ExtendedCode {
+		case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+			return &sentinelError{ErrConflict, err}
+		}
+
+		switch sqliteErr.Code {
+		case sqlite3.ErrInterrupt:
+			// The driver interrupts a running statement when its context
+			// is done.
+			return &sentinelError{ErrContextCanceled, err}
+		case sqlite3.ErrReadonly:
+			return &sentinelError{ErrReadOnly, err}
+		case sqlite3.ErrFull, sqlite3.ErrIoErr:
+			return &sentinelError{ErrStorageFull, err}
+		}
+	}
+
+	return err
+}
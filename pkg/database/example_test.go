@@ -0,0 +1,60 @@
+package database_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"example.com/pkg/database"
+)
+
+func ExampleSqlite_NamedExecContext() {
+	dir, err := os.MkdirTemp("", "example")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := database.New(filepath.Join(dir, "example.db"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE users (name TEXT, email TEXT)`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	type User struct {
+		Name  string `db:"name"`
+		Email string `db:"email"`
+	}
+
+	user := User{Name: "Alice", Email: "alice@example.com"}
+
+	_, err = db.NamedExecContext(ctx, `INSERT INTO users (name, email) VALUES (:name, :email)`, user)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	rows, err := db.NamedQueryContext(ctx, `SELECT name, email FROM users WHERE name = :name`, user)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var u User
+		if err := rows.StructScan(&u); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(u.Name, u.Email)
+	}
+
+	// Output: Alice alice@example.com
+}
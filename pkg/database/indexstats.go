@@ -0,0 +1,103 @@
+package database
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// IndexStat holds the query planner's estimates for one index.
+type IndexStat struct {
+	Table string `json:"table"`
+	Index string `json:"index"`
+
+	// Analyzed is false when sqlite_stat1 has no entry for the index, which
+	// is also the case for indexes on empty tables.
+	Analyzed bool `json:"analyzed"`
+
+	// Rows is the estimated number of rows in the index.
+	Rows int64 `json:"rows"`
+
+	// RowsPerKey holds the estimated average number of rows matched by an
+	// equality constraint on the first N columns of the index, for each N. A
+	// value close to Rows for the first column means the index is barely
+	// selective and is likely unused or redundant.
+	RowsPerKey []int64 `json:"rows_per_key"`
+}
+
+// IndexStats returns estimates for every index in the database, ordered by
+// table and index name, for spotting unused or redundant indexes.
+//
+// The estimates come from sqlite_stat1, which is only populated by ANALYZE.
+// If it doesn't exist yet, IndexStats runs ANALYZE first, unless the database
+// is read-only, in which case every index is reported as not analyzed. The
+// estimates are only as fresh as the last ANALYZE.
+func (db *Sqlite) IndexStats() ([]IndexStat, error) {
+	var analyzed bool
+	err := db.db.GetContext(db.ctx, &analyzed, `SELECT count(*) > 0 FROM sqlite_master WHERE name = 'sqlite_stat1'`)
+	if err != nil {
+		return nil, err
+	}
+
+	if !analyzed && !db.readOnly {
+		if _, err := db.db.ExecContext(db.ctx, `ANALYZE`); err != nil {
+			return nil, db.writeError(err)
+		}
+		analyzed = true
+	}
+
+	query := `
+		SELECT tbl_name, name, NULL AS stat FROM sqlite_master
+		WHERE type = 'index'
+		ORDER BY tbl_name, name`
+	if analyzed {
+		query = `
+			SELECT m.tbl_name, m.name, s.stat FROM sqlite_master m
+			LEFT JOIN sqlite_stat1 s ON s.idx = m.name
+			WHERE m.type = 'index'
+			ORDER BY m.tbl_name, m.name`
+	}
+
+	rows, err := db.db.QueryContext(db.ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []IndexStat
+	for rows.Next() {
+		var s IndexStat
+		var stat sql.NullString
+		if err := rows.Scan(&s.Table, &s.Index, &stat); err != nil {
+			return nil, err
+		}
+		if stat.Valid {
+			s.Analyzed = true
+			s.Rows, s.RowsPerKey = parseIndexStat(stat.String)
+		}
+		stats = append(stats, s)
+	}
+
+	return stats, rows.Err()
+}
+
+// parseIndexStat parses a sqlite_stat1 stat value, a list of integers
+// optionally followed by keywords such as "unordered", which are ignored.
+func parseIndexStat(stat string) (int64, []int64) {
+	var rows int64
+	var perKey []int64
+
+	for i, f := range strings.Fields(stat) {
+		n, err := strconv.ParseInt(f, 10, 64)
+		if err != nil {
+			break
+		}
+		if i == 0 {
+			rows = n
+		} else {
+			perKey = append(perKey, n)
+		}
+	}
+
+	return rows, perKey
+}
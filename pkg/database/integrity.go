@@ -0,0 +1,65 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CorruptError is returned by New when the database fails its integrity check
+// and could not be recovered. Callers may want to restore from a backup.
+type CorruptError struct {
+	Problems []string
+}
+
+func (e *CorruptError) Error() string {
+	return fmt.Sprintf("database is corrupt: %s", strings.Join(e.Problems, "; "))
+}
+
+// checkIntegrity runs an integrity check and, if it fails, checkpoints the WAL into
+// the main database file and checks again. After an unclean shutdown this is
+// often enough to get back to a consistent state.
+func (db *Sqlite) checkIntegrity() error {
+	problems, err := db.quickCheck()
+	if err == nil && len(problems) == 0 {
+		return nil
+	}
+
+	if err != nil {
+		db.logger.Warning("integrity check failed: %s", err)
+	} else {
+		db.logger.Warning("integrity check reported problems: %s", strings.Join(problems, "; "))
+	}
+
+	db.logger.Info("attempting wal checkpoint")
+	if _, err := db.db.Exec(`PRAGMA wal_checkpoint(TRUNCATE);`); err != nil {
+		db.logger.Warning("wal checkpoint failed: %s", err)
+	}
+
+	db.logger.Info("retrying integrity check")
+	problems, err = db.quickCheck()
+	if err != nil {
+		return &CorruptError{Problems: []string{err.Error()}}
+	}
+	if len(problems) != 0 {
+		return &CorruptError{Problems: problems}
+	}
+
+	db.logger.Info("database recovered after wal checkpoint")
+	return nil
+}
+
+// quickCheck runs PRAGMA quick_check and returns any problems it reports.
+func (db *Sqlite) quickCheck() ([]string, error) {
+	var results []string
+	if err := db.db.Select(&results, `PRAGMA quick_check;`); err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	for _, result := range results {
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"bytes"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"example.com/pkg/leveledlog"
+)
+
+func TestMigrateBOMPrefixed(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migration/00000_widgets.sql": {Data: []byte("\uFEFF\n  CREATE TABLE widgets (id INTEGER PRIMARY KEY);  \n\n")},
+	}
+
+	db := newTestDB(t, WithMigrationFS(fsys))
+
+	var n int
+	if err := db.db.Get(&n, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'widgets'`); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("got %d widgets tables; want 1", n)
+	}
+}
+
+func TestMigrateCommentOnly(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migration/00000_empty.sql": {Data: []byte("-- nothing to see here\n/* or here */\n")},
+	}
+
+	var buf bytes.Buffer
+	logger := leveledlog.NewLogger(&buf, leveledlog.LevelAll, false)
+
+	newTestDB(t, WithMigrationFS(fsys), WithLogger(logger))
+
+	var warning string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.Contains(line, `level="WARNING"`) {
+			warning = line
+		}
+	}
+	if !strings.Contains(warning, "migration contains no statements") {
+		t.Errorf("got log %q; want a no statements warning", buf.String())
+	}
+	if !strings.Contains(warning, `migration="migration/00000_empty.sql"`) {
+		t.Errorf("got warning %q; want it tagged with the migration", warning)
+	}
+}
+
+func TestMigrationsSinceSameSecond(t *testing.T) {
+	since := time.Now()
+
+	db := newTestDB(t)
+
+	infos, err := db.MigrationsSince(since)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := MigrationNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != len(names) {
+		t.Fatalf("got %d migrations; want %d", len(infos), len(names))
+	}
+	for i, info := range infos {
+		if info.Name != names[i] {
+			t.Errorf("got migration %d %s; want %s", i, info.Name, names[i])
+		}
+	}
+}
+
+func TestMigrationsTableUpgradedUnderLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	// A migrations table from before applied_at was recorded.
+	old, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.Exec(`CREATE TABLE migrations (name TEXT PRIMARY KEY)`); err != nil {
+		t.Fatal(err)
+	}
+	old.Close()
+
+	// Instances starting together must not fail adding the column twice.
+	errs := make(chan error, 4)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			db, err := New(path)
+			if err == nil {
+				db.Close()
+			}
+			errs <- err
+		}()
+	}
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestMigrationLockHeartbeat(t *testing.T) {
+	defer func(d time.Duration) { migrationLockHeartbeat = d }(migrationLockHeartbeat)
+	migrationLockHeartbeat = 10 * time.Millisecond
+
+	db := newTestDB(t)
+
+	unlock, err := db.lockMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	// Age the lock so it would be taken over if it weren't refreshed.
+	if _, err := db.db.Exec(`UPDATE migration_lock SET acquired_at = 0 WHERE id = 1`); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var since int64
+		if err := db.db.Get(&since, `SELECT acquired_at FROM migration_lock WHERE id = 1`); err != nil {
+			t.Fatal(err)
+		}
+		if since > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("migration lock was not refreshed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// staleMigrationLock is the age after which a migration lock is assumed to have
+// been abandoned by an instance that crashed mid-migration, and is taken over.
+// The holder refreshes the lock every migrationLockHeartbeat, so migrations
+// that run for longer than this keep it.
+const staleMigrationLock = 10 * time.Minute
+
+var migrationLockHeartbeat = time.Minute
+
+// lockMigrations takes the advisory migration lock, a single row in the
+// migration_lock table, so that when several instances start at once only one
+// runs migrations while the others wait for it.
+//
+// The lock is polled for up to the wait set by WithMigrationLockWait. Each
+// attempt is a short write transaction, so waiting instances don't hold the
+// database write lock and the busy_timeout isn't what decides the outcome.
+func (db *Sqlite) lockMigrations() (func(), error) {
+	if _, err := db.db.Exec(`CREATE TABLE IF NOT EXISTS migration_lock (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		owner TEXT NOT NULL,
+		acquired_at INTEGER NOT NULL
+	);`); err != nil {
+		return nil, fmt.Errorf("cannot create migration_lock table: %w", err)
+	}
+
+	host, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d", host, os.Getpid())
+
+	deadline := time.Now().Add(db.migrationLockWait)
+	logged := false
+
+	for {
+		now := time.Now()
+
+		res, err := db.db.Exec(`
+			INSERT INTO migration_lock (id, owner, acquired_at) VALUES (1, ?, ?)
+			ON CONFLICT (id) DO UPDATE SET owner = excluded.owner, acquired_at = excluded.acquired_at
+			WHERE acquired_at < ?`,
+			owner, now.Unix(), now.Add(-staleMigrationLock).Unix())
+		if err != nil {
+			return nil, fmt.Errorf("cannot take migration lock: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			break
+		}
+
+		var holder string
+		var since int64
+		err = db.db.QueryRow(`SELECT owner, acquired_at FROM migration_lock WHERE id = 1`).Scan(&holder, &since)
+		if errors.Is(err, sql.ErrNoRows) {
+			// The holder released the lock between the two statements.
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read migration lock: %w", err)
+		}
+
+		if now.After(deadline) {
+			return nil, fmt.Errorf("migration lock held by %s since %s was not released within %s",
+				holder, time.Unix(since, 0).UTC().Format(time.RFC3339), db.migrationLockWait)
+		}
+
+		if !logged {
+			db.logger.Info("waiting up to %s for migration lock held by %s", db.migrationLockWait, holder)
+			logged = true
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go db.refreshMigrationLock(owner, stop, done)
+
+	unlock := func() {
+		close(stop)
+		<-done
+
+		if _, err := db.db.Exec(`DELETE FROM migration_lock WHERE id = 1 AND owner = ?`, owner); err != nil {
+			db.logger.Warning("unable to release migration lock: %s", err)
+		}
+	}
+	return unlock, nil
+}
+
+// refreshMigrationLock updates the acquired_at time of the migration lock held
+// by owner every migrationLockHeartbeat until stop is closed, so other
+// instances don't take over the lock of a long-running migration as stale.
+// It closes done once it has stopped.
+func (db *Sqlite) refreshMigrationLock(owner string, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(migrationLockHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			_, err := db.db.Exec(`UPDATE migration_lock SET acquired_at = ? WHERE id = 1 AND owner = ?`, time.Now().Unix(), owner)
+			if err != nil {
+				db.logger.Warning("unable to refresh migration lock: %s", err)
+			}
+		}
+	}
+}
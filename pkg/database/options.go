@@ -0,0 +1,177 @@
+package database
+
+import (
+	"io/fs"
+	"time"
+
+	"example.com/pkg/leveledlog"
+)
+
+// Option configures a Sqlite instance created by New.
+type Option func(*Sqlite)
+
+// WithMigrationFS replaces the embedded migration files with those in fsys.
+// Like the embedded set, the files must live in a "migration" directory at the
+// root of fsys and are executed in lexigraphical order.
+func WithMigrationFS(fsys fs.FS) Option {
+	return func(db *Sqlite) {
+		db.migrationFS = fsys
+	}
+}
+
+// WithLogger sets the logger used to report database events. By default
+// nothing is logged.
+func WithLogger(logger *leveledlog.Logger) Option {
+	return func(db *Sqlite) {
+		db.logger = logger
+	}
+}
+
+// WithIntegrityCheck runs PRAGMA quick_check when the database is opened. If
+// the check fails, a WAL checkpoint is attempted before checking again, and a
+// *CorruptError is returned if the database still fails the check.
+func WithIntegrityCheck() Option {
+	return func(db *Sqlite) {
+		db.integrityCheck = true
+	}
+}
+
+// WithDefaultQueryTimeout sets a deadline of d on queries run through the
+// wrapper methods when the caller's context doesn't already have one. This
+// catches runaway queries from background code. A zero value disables it.
+func WithDefaultQueryTimeout(d time.Duration) Option {
+	return func(db *Sqlite) {
+		db.queryTimeout = d
+	}
+}
+
+// WithReadOnly opens the database with mode=ro and skips migrations. Writes
+// fail with ErrReadOnly.
+//
+// This suits a reporting process attached to the same file a primary process
+// writes to. In WAL mode readers still need the -wal and -shm files next to
+// the database: if they don't exist, and the directory isn't writable, opening
+// the database fails, so the primary should already have it open in WAL mode.
+func WithReadOnly() Option {
+	return func(db *Sqlite) {
+		db.readOnly = true
+	}
+}
+
+// WithMigrationBatchSize sets the default number of statements executed per
+// transaction for migrations marked with a "-- batch" directive. A migration
+// can override it with "-- batch <size>". The default is 100.
+func WithMigrationBatchSize(n int) Option {
+	return func(db *Sqlite) {
+		db.migrationBatchSize = n
+	}
+}
+
+// WithSlowMigrationThreshold logs a warning for any migration that takes
+// longer than d to run. Zero disables the warning.
+func WithSlowMigrationThreshold(d time.Duration) Option {
+	return func(db *Sqlite) {
+		db.slowMigrationThreshold = d
+	}
+}
+
+// WithReplication sets whether the database is replicated by litestream.
+// Litestream needs to control checkpointing so it can copy each WAL segment
+// before it is folded into the database file, so when enabled
+// wal_autocheckpoint is set to 0. Don't combine it with StartCheckpointer.
+//
+// When the option isn't given, replication is assumed if the
+// LITESTREAM_ACCESS_KEY environment variable is set.
+func WithReplication(enabled bool) Option {
+	return func(db *Sqlite) {
+		db.replication = enabled
+	}
+}
+
+// WithMigrationTemplateData renders migrations marked with a "-- template"
+// comment line before their first statement as text/template templates with
+// data, so environment-specific values can be written as placeholders:
+//
+//	-- template
+//	INSERT INTO tenants (id, name) VALUES ({{.DefaultTenantID}}, 'default');
+//
+// Values are substituted as is, not as bound parameters, so they must come
+// from trusted configuration and be quoted in the template where needed. The
+// rendered SQL is what is executed, so identical data always produces
+// identical migrations.
+func WithMigrationTemplateData(data map[string]any) Option {
+	return func(db *Sqlite) {
+		db.templateData = data
+	}
+}
+
+// WithAllMigrationsTemplated renders every migration with the data given to
+// WithMigrationTemplateData, without needing the "-- template" directive.
+// Migrations must then escape any literal "{{" in their SQL.
+func WithAllMigrationsTemplated() Option {
+	return func(db *Sqlite) {
+		db.templateAll = true
+	}
+}
+
+// WithQueryCache enables the result cache used by CachedGet, holding up to size
+// results. Once full, the least recently used result is evicted.
+func WithQueryCache(size int) Option {
+	return func(db *Sqlite) {
+		if size > 0 {
+			db.cache = newQueryCache(size)
+		}
+	}
+}
+
+// WithMigrationLockWait sets how long New waits for another instance to finish
+// migrating before giving up. The default is one minute.
+//
+// When several instances start against the same file at once, the first to
+// take the migration lock runs the pending migrations and the others wait,
+// then find nothing left to apply. If the wait is exceeded New fails with an
+// error naming the holder of the lock. A lock left behind by an instance that
+// crashed mid-migration is taken over after ten minutes.
+func WithMigrationLockWait(d time.Duration) Option {
+	return func(db *Sqlite) {
+		db.migrationLockWait = d
+	}
+}
+
+// WithReadReplica opens a second, read-only pool on dsn, typically a replica
+// kept up to date by litestream, and routes GetContext and SelectContext to
+// it, and so also CachedGet. Everything else, including ExecContext and WithTx,
+// uses the primary.
+//
+// A replica lags behind the primary, so a read straight after a write may not
+// see it. Wrap the context with UsePrimary for reads that must.
+func WithReadReplica(dsn string) Option {
+	return func(db *Sqlite) {
+		db.replicaDSN = dsn
+	}
+}
+
+// WithIncrementalAutoVacuum sets auto_vacuum=INCREMENTAL, so that the free
+// pages left by deleted rows can be reclaimed a few at a time with
+// IncrementalVacuum instead of a full VACUUM, which rewrites the whole file
+// and blocks writers while it runs.
+//
+// auto_vacuum is stored in the database file and can only be changed before
+// the first table is created, so the option must be in place when the
+// database is created. For an existing database it only takes effect after a
+// full VACUUM; New logs a warning until then.
+func WithIncrementalAutoVacuum() Option {
+	return func(db *Sqlite) {
+		db.incrementalVacuum = true
+	}
+}
+
+// WithMigrationMode sets what New does about pending migrations: apply them
+// (MigrationAuto, the default), fail listing them (MigrationCheck) or leave
+// them alone (MigrationSkip). A read-only database is never migrated, but can
+// still be checked.
+func WithMigrationMode(mode MigrationMode) Option {
+	return func(db *Sqlite) {
+		db.migrationMode = mode
+	}
+}
@@ -0,0 +1,224 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"example.com/pkg/leveledlog"
+	"github.com/jmoiron/sqlx"
+)
+
+// withTimeout applies the default query timeout to ctx if it is enabled and ctx
+// doesn't already have a deadline. The returned cancel func must be called once
+// the query has completed.
+func (db *Sqlite) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.queryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, db.queryTimeout)
+}
+
+// writeError maps err and records whether the write failed because storage is
+// full, so Ready can report it.
+func (db *Sqlite) writeError(err error) error {
+	err = mapError(err)
+	if errors.Is(err, ErrStorageFull) {
+		atomic.StoreInt32(&db.storageFull, 1)
+	} else if err == nil {
+		atomic.StoreInt32(&db.storageFull, 0)
+	}
+	return err
+}
+
+// observeQuery is deferred by the query methods. It counts the query on the
+// QueryCounter in ctx, notes on the QueryTracker in ctx if the query ran into
+// the context deadline, and logs query at Debug level against the logger
+// stored in ctx, if any. Outside of a request there is normally no logger in
+// the context, in which case nothing is logged.
+func observeQuery(ctx context.Context, query string, start time.Time) {
+	countQuery(ctx)
+	trackTimeout(ctx, query)
+
+	logger := leveledlog.FromContext(ctx)
+	if logger == nil || !logger.Enabled(leveledlog.LevelDebug) {
+		return
+	}
+
+	logger.WithFields(leveledlog.Fields{
+		"query":    query,
+		"duration": time.Since(start).String(),
+	}).Debug("sql query")
+}
+
+const primaryContextKey = contextKey("primary")
+
+// UsePrimary returns a copy of ctx that routes reads to the primary database
+// rather than the read replica, for read-after-write consistency.
+func UsePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey, true)
+}
+
+// reader returns what reads made with ctx should run on: the transaction in
+// ctx, if any, else the read replica unless ctx asks for the primary.
+func (db *Sqlite) reader(ctx context.Context) sqlx.ExtContext {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	if db.replica == nil {
+		return db.db
+	}
+	if primary, _ := ctx.Value(primaryContextKey).(bool); primary {
+		return db.db
+	}
+	return db.replica
+}
+
+// primary returns what queries made with ctx that don't use the read replica
+// should run on: the transaction in ctx, if any, else the primary pool.
+func (db *Sqlite) primary(ctx context.Context) sqlx.ExtContext {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	return db.db
+}
+
+// ExecContext executes a query without returning any rows.
+func (db *Sqlite) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+	defer observeQuery(ctx, query, time.Now())
+
+	result, err := db.primary(ctx).ExecContext(ctx, query, args...)
+	return result, db.writeError(err)
+}
+
+// GetContext runs a query and scans the single resulting row into dest. It
+// reads from the read replica, if there is one.
+func (db *Sqlite) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+	defer observeQuery(ctx, query, time.Now())
+
+	return mapError(sqlx.GetContext(ctx, db.reader(ctx), dest, query, args...))
+}
+
+// SelectContext runs a query and scans each resulting row into dest, which
+// must be a pointer to a slice. It reads from the read replica, if there is
+// one.
+func (db *Sqlite) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+	defer observeQuery(ctx, query, time.Now())
+
+	return mapError(sqlx.SelectContext(ctx, db.reader(ctx), dest, query, args...))
+}
+
+// QueryxContext runs a query and returns the resulting rows, which the caller
+// must close. The rows outlive this call, so the default query timeout is not
+// applied; the caller's context is used as is.
+func (db *Sqlite) QueryxContext(ctx context.Context, query string, args ...any) (*sqlx.Rows, error) {
+	defer observeQuery(ctx, query, time.Now())
+
+	rows, err := db.primary(ctx).QueryxContext(ctx, query, args...)
+	return rows, mapError(err)
+}
+
+// EachRow runs a query and scans the resulting rows into dest one at a time,
+// calling fn after each, so large results can be streamed without loading them
+// all into memory. dest is reused for every row: it is a pointer to a struct
+// with `db` tags, or to a single value for one-column results.
+//
+// Iteration stops at the first error returned by fn, which EachRow returns.
+// The rows are always closed before EachRow returns. As with QueryxContext, the
+// default query timeout is not applied.
+//
+//	var u User
+//	err := db.EachRow(ctx, &u, `SELECT * FROM users`, func() error {
+//		return enc.Encode(u)
+//	})
+func (db *Sqlite) EachRow(ctx context.Context, dest any, query string, fn func() error, args ...any) error {
+	defer observeQuery(ctx, query, time.Now())
+
+	rows, err := db.primary(ctx).QueryxContext(ctx, query, args...)
+	if err != nil {
+		return mapError(err)
+	}
+	defer rows.Close()
+
+	structScan := isStructDest(dest)
+
+	for rows.Next() {
+		if structScan {
+			err = rows.StructScan(dest)
+		} else {
+			err = rows.Scan(dest)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+
+	return mapError(rows.Err())
+}
+
+// isStructDest reports whether dest points to a struct whose fields should be
+// scanned individually, rather than a value that scans a single column.
+func isStructDest(dest any) bool {
+	if _, ok := dest.(sql.Scanner); ok {
+		return false
+	}
+	if _, ok := dest.(*time.Time); ok {
+		return false
+	}
+	v := reflect.ValueOf(dest)
+	return v.Kind() == reflect.Pointer && v.Elem().Kind() == reflect.Struct
+}
+
+// QueryRowxContext runs a query that is expected to return at most one row.
+// As with QueryxContext, the default query timeout is not applied.
+func (db *Sqlite) QueryRowxContext(ctx context.Context, query string, args ...any) *sqlx.Row {
+	defer observeQuery(ctx, query, time.Now())
+
+	return db.primary(ctx).QueryRowxContext(ctx, query, args...)
+}
+
+// NamedExecContext executes a query with named parameters bound from arg, which
+// may be a struct with `db` tags or a map[string]any. For example:
+//
+//	db.NamedExecContext(ctx, `INSERT INTO users (name, email) VALUES (:name, :email)`, user)
+func (db *Sqlite) NamedExecContext(ctx context.Context, query string, arg any) (sql.Result, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+	defer observeQuery(ctx, query, time.Now())
+
+	result, err := sqlx.NamedExecContext(ctx, db.primary(ctx), query, arg)
+	return result, db.writeError(err)
+}
+
+// NamedQueryContext runs a query with named parameters bound from arg and
+// returns the resulting rows, which the caller must close. As with
+// QueryxContext, the default query timeout is not applied.
+func (db *Sqlite) NamedQueryContext(ctx context.Context, query string, arg any) (*sqlx.Rows, error) {
+	defer observeQuery(ctx, query, time.Now())
+
+	rows, err := sqlx.NamedQueryContext(ctx, db.primary(ctx), query, arg)
+	return rows, mapError(err)
+}
+
+// DB returns the underlying *sqlx.DB. It is an escape hatch for functionality
+// that isn't wrapped by Sqlite, such as PrepareNamed or custom mappers; queries
+// run through it bypass any behaviour added by the wrapper methods.
+func (db *Sqlite) DB() *sqlx.DB {
+	return db.db
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// slowQuery counts forever, so it only returns once its context is done.
+const slowQuery = `WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c) SELECT count(*) FROM c`
+
+func TestContextCanceledDuringQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		cancel func() (context.Context, context.CancelFunc)
+	}{
+		{
+			name: "canceled",
+			cancel: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(50*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+		},
+		{
+			name: "deadline exceeded",
+			cancel: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
+			},
+		},
+	}
+
+	db := newTestDB(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.cancel()
+			defer cancel()
+
+			var n int
+			err := db.GetContext(ctx, &n, slowQuery)
+			if !errors.Is(err, ErrContextCanceled) {
+				t.Fatalf("got error %v; want ErrContextCanceled", err)
+			}
+
+			if inUse := db.DB().Stats().InUse; inUse != 0 {
+				t.Errorf("got %d connections in use; want 0", inUse)
+			}
+
+			if err := db.GetContext(context.Background(), &n, "SELECT 1"); err != nil {
+				t.Errorf("query after cancellation: got %v; want nil", err)
+			}
+		})
+	}
+}
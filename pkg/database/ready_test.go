@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestReadyRecoversFromFullStorage(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	// max_page_count is set per connection, so use just one.
+	db.DB().SetMaxOpenConns(1)
+
+	if _, err := db.ExecContext(ctx, `CREATE TABLE blobs (data BLOB)`); err != nil {
+		t.Fatal(err)
+	}
+
+	var pages int
+	if err := db.GetContext(ctx, &pages, `PRAGMA page_count`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(`PRAGMA max_page_count = %d`, pages)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := db.ExecContext(ctx, `INSERT INTO blobs (data) VALUES (randomblob(65536))`)
+	if !errors.Is(err, ErrStorageFull) {
+		t.Fatalf("got error %v; want ErrStorageFull", err)
+	}
+
+	if err := db.Ready(ctx); !errors.Is(err, ErrStorageFull) {
+		t.Errorf("while full: got %v; want ErrStorageFull", err)
+	}
+
+	// Free up space without any request writing to the database.
+	if _, err := db.DB().ExecContext(ctx, `PRAGMA max_page_count = 1000000`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Ready(ctx); err != nil {
+		t.Errorf("after freeing space: got %v; want nil", err)
+	}
+	if err := db.Ready(ctx); err != nil {
+		t.Errorf("second check after freeing space: got %v; want nil", err)
+	}
+}
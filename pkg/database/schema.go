@@ -0,0 +1,29 @@
+package database
+
+import "strings"
+
+// Schema returns the CREATE statements for all tables, views, indexes and
+// triggers in the database, ordered by type and then name so the output is
+// stable enough to diff.
+func (db *Sqlite) Schema() (string, error) {
+	var statements []string
+
+	err := db.db.SelectContext(db.ctx, &statements, `
+		SELECT sql FROM sqlite_master
+		WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+		ORDER BY CASE type
+			WHEN 'table' THEN 0
+			WHEN 'view' THEN 1
+			WHEN 'index' THEN 2
+			WHEN 'trigger' THEN 3
+			ELSE 4
+		END, name`)
+	if err != nil {
+		return "", err
+	}
+
+	if len(statements) == 0 {
+		return "", nil
+	}
+	return strings.Join(statements, ";\n\n") + ";\n", nil
+}
@@ -0,0 +1,23 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// ExecScript runs a multi-statement SQL script within a single transaction,
+// rolling back if any statement fails. Unlike a migration, nothing is recorded
+// in the migrations table. It's intended for one-off operational fixes and
+// must only be exposed behind proper authorization.
+func (db *Sqlite) ExecScript(ctx context.Context, script string) error {
+	statements := splitStatements(script)
+
+	return db.WithTx(ctx, func(tx *Tx) error {
+		for i, stmt := range statements {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("statement %d: %w", i+1, err)
+			}
+		}
+		return nil
+	})
+}
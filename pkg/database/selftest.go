@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// SelfTest checks that the database is fully functional, not just reachable,
+// by creating a scratch table, writing a row to it, reading it back and
+// dropping the table, all in one transaction that is then committed. This
+// catches problems a ping doesn't, such as a read-only filesystem or a full
+// disk.
+//
+// The scratch table, _selftest, only exists within the transaction, so it
+// needs no migration and never shows up in the schema or a Dump. If any step
+// fails the transaction is rolled back, so nothing is left behind either.
+func (db *Sqlite) SelfTest(ctx context.Context) error {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(b)
+
+	return db.WithTx(ctx, func(tx *Tx) error {
+		if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS _selftest (token TEXT PRIMARY KEY);`); err != nil {
+			return fmt.Errorf("create scratch table: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `INSERT INTO _selftest (token) VALUES (?)`, token); err != nil {
+			return fmt.Errorf("write: %w", err)
+		}
+
+		var got string
+		if err := tx.GetContext(ctx, &got, `SELECT token FROM _selftest WHERE token = ?`, token); err != nil {
+			return fmt.Errorf("read: %w", mapError(err))
+		}
+		if got != token {
+			return fmt.Errorf("read: got %q, want %q", got, token)
+		}
+
+		if _, err := tx.ExecContext(ctx, `DROP TABLE _selftest;`); err != nil {
+			return fmt.Errorf("drop scratch table: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,22 @@
+package database
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSelfTest(t *testing.T) {
+	db := newTestDB(t)
+
+	if err := db.SelfTest(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	if err := db.GetContext(context.Background(), &n, `SELECT COUNT(*) FROM sqlite_master WHERE name = '_selftest'`); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Error("got the scratch table left behind; want it dropped")
+	}
+}
@@ -0,0 +1,41 @@
+package database
+
+// SizeInfo describes the size of the database file, for capacity planning and
+// deciding when a vacuum is worthwhile.
+type SizeInfo struct {
+	PageCount     int64 `json:"page_count"`
+	PageSize      int64 `json:"page_size"`
+	FreelistCount int64 `json:"freelist_count"`
+
+	// TotalBytes is the size of the database, PageCount*PageSize. It doesn't
+	// include the WAL file.
+	TotalBytes int64 `json:"total_bytes"`
+
+	// FreeBytes is the space held by free pages, FreelistCount*PageSize,
+	// which IncrementalVacuum or VACUUM would return to the filesystem.
+	FreeBytes int64 `json:"free_bytes"`
+}
+
+// SizeInfo reports the size of the database and how much of it is free pages
+// that could be reclaimed. It only reads PRAGMAs.
+func (db *Sqlite) SizeInfo() (SizeInfo, error) {
+	var info SizeInfo
+
+	pragmas := []struct {
+		query string
+		dest  *int64
+	}{
+		{`PRAGMA page_count;`, &info.PageCount},
+		{`PRAGMA page_size;`, &info.PageSize},
+		{`PRAGMA freelist_count;`, &info.FreelistCount},
+	}
+	for _, p := range pragmas {
+		if err := db.db.GetContext(db.ctx, p.dest, p.query); err != nil {
+			return SizeInfo{}, mapError(err)
+		}
+	}
+
+	info.TotalBytes = info.PageCount * info.PageSize
+	info.FreeBytes = info.FreelistCount * info.PageSize
+	return info, nil
+}
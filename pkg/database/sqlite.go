@@ -2,88 +2,392 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
 	"embed"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
+	"path"
+	"regexp"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 
+	"example.com/pkg/leveledlog"
 	"github.com/jmoiron/sqlx"
-
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
+	"golang.org/x/sync/singleflight"
 )
 
 //go:embed migration/*.sql
 var migrationFS embed.FS
 
 type Sqlite struct {
-	db *sqlx.DB
+	db  *sqlx.DB
+	dsn string
+
+	// immediate is the single-connection pool opened on first use by
+	// TxWithRetry, whose transactions take the write lock at BEGIN.
+	immediateMu sync.Mutex
+	immediate   *sqlx.DB
+
+	// replica is the read-only pool opened on replicaDSN by WithReadReplica,
+	// or nil.
+	replicaDSN string
+	replica    *sqlx.DB
+
+	migrationFS    fs.FS
+	logger         *leveledlog.Logger
+	integrityCheck bool
+	queryTimeout   time.Duration
+	readOnly       bool
+	replication    bool
+
+	incrementalVacuum bool
 
-	ctx    context.Context
-	cancel func()
+	migrationBatchSize     int
+	slowMigrationThreshold time.Duration
+	templateData           map[string]any
+	templateAll            bool
+	migrationLockWait      time.Duration
+	migrationMode          MigrationMode
+
+	cache  *queryCache
+	flight singleflight.Group
+
+	// storageFull is set to 1 when the last write failed with ErrStorageFull,
+	// and cleared by the next write, or Ready probe, that succeeds.
+	storageFull int32
+
+	checkpointerOnce sync.Once
+	vacuumOnce       sync.Once
+
+	ctx       context.Context
+	cancel    func()
+	closeOnce sync.Once
 }
 
-func New(dsn string) (*Sqlite, error) {
-	sqlxDB, err := sqlx.Connect("sqlite3", dsn)
+// New opens the SQLite database at dsn, configures the connection pool and
+// runs any pending migrations.
+//
+// Each call returns an independent instance with its own pool and migration
+// set, so an application can hold several databases side by side:
+//
+//	primary, err := database.New("data/primary.db")
+//	...
+//	events, err := database.New("data/events.db", database.WithMigrationFS(eventsMigrations))
+//	...
+//	app := &application{db: primary, events: events}
+func New(dsn string, opts ...Option) (*Sqlite, error) {
+	db := &Sqlite{
+		migrationFS:        migrationFS,
+		migrationBatchSize: 100,
+		migrationLockWait:  time.Minute,
+		logger:             leveledlog.NewLogger(io.Discard, leveledlog.LevelOff, false),
+		replication:        os.Getenv("LITESTREAM_ACCESS_KEY") != "",
+	}
+
+	for _, opt := range opts {
+		opt(db)
+	}
+
+	if db.readOnly {
+		dsn = readOnlyDSN(dsn)
+	}
+
+	sqlxDB, err := db.open(dsn, db.readOnly)
 	if err != nil {
 		return nil, err
 	}
+	db.db = sqlxDB
+	db.dsn = dsn
 
 	ctx, cancel := context.WithCancel(context.Background())
-	db := &Sqlite{sqlxDB, ctx, cancel}
+	db.ctx = ctx
+	db.cancel = cancel
+
+	// Close the pools and stop anything started on the background context
+	// if any later step fails, so a failed New doesn't leak them.
+	if err := db.setup(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
 
+	return db, nil
+}
+
+// setup configures the connection pools and brings the schema up to date. It
+// is called by New once the primary pool is open.
+func (db *Sqlite) setup(ctx context.Context) error {
 	db.db.SetMaxOpenConns(25)
 	db.db.SetMaxIdleConns(25)
 	db.db.SetConnMaxIdleTime(5 * time.Minute)
 	db.db.SetConnMaxLifetime(2 * time.Hour)
 
-	// WAL mode is required for concurrent writes.
-	if _, err := db.db.Exec(`PRAGMA journal_mode = wal;`); err != nil {
-		return nil, fmt.Errorf("enable wal: %w", err)
+	if db.incrementalVacuum && !db.readOnly {
+		if err := db.checkIncrementalVacuum(ctx); err != nil {
+			return err
+		}
+	}
+
+	if db.replicaDSN != "" {
+		replica, err := db.open(readOnlyDSN(db.replicaDSN), true)
+		if err != nil {
+			return fmt.Errorf("open read replica: %w", err)
+		}
+		replica.SetMaxOpenConns(25)
+		replica.SetMaxIdleConns(25)
+		replica.SetConnMaxIdleTime(5 * time.Minute)
+		replica.SetConnMaxLifetime(2 * time.Hour)
+		db.replica = replica
+	}
+
+	if db.integrityCheck {
+		if err := db.checkIntegrity(); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case db.migrationMode == MigrationSkip:
+	case db.migrationMode == MigrationCheck:
+		if err := db.checkMigrations(); err != nil {
+			return err
+		}
+	case !db.readOnly:
+		// A read-only database can't record migrations, so they are left to
+		// the process that owns the database.
+		if err := db.migrate(); err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// readOnlyDSN adds mode=ro to dsn. The driver only passes URI parameters
+// through to SQLite for "file:" DSNs, so plain paths are converted.
+func readOnlyDSN(dsn string) string {
+	if !strings.HasPrefix(dsn, "file:") {
+		dsn = "file:" + dsn
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&mode=ro"
+	}
+	return dsn + "?mode=ro"
+}
+
+// connector opens connections on dsn with driver, so that each has its
+// pragmas applied by the driver's ConnectHook.
+type connector struct {
+	driver *sqlite3.SQLiteDriver
+	dsn    string
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// open opens a pool on dsn and checks that it can connect. Every connection
+// the pool opens, including those it replaces after ConnMaxLifetime, is
+// configured by pragmas before it is used. readOnly reports whether dsn is
+// read-only, so the settings that write to the database file are skipped.
+func (db *Sqlite) open(dsn string, readOnly bool) (*sqlx.DB, error) {
+	drv := &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return db.pragmas(conn, readOnly)
+		},
+	}
+
+	pool := sqlx.NewDb(sql.OpenDB(connector{driver: drv, dsn: dsn}), "sqlite3")
+	if err := pool.Ping(); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return pool, nil
+}
+
+// pragmas configures conn, which is read-only if it belongs to a read-only
+// database or to the read replica. Most of these settings are scoped to a
+// single connection, which is why they are applied as each connection is
+// opened rather than once for the pool.
+func (db *Sqlite) pragmas(conn *sqlite3.SQLiteConn, readOnly bool) error {
+	exec := func(query string) error {
+		_, err := conn.Exec(query, nil)
+		return err
+	}
+
+	// auto_vacuum has to be set before WAL mode writes the database header.
+	// Once the migrations have created tables it no longer changes the mode,
+	// see checkIncrementalVacuum.
+	if db.incrementalVacuum && !readOnly {
+		if err := exec(`PRAGMA auto_vacuum = INCREMENTAL;`); err != nil {
+			return fmt.Errorf("auto vacuum pragma: %w", err)
+		}
+	}
+
+	// WAL mode is required for concurrent writes. The journal mode is stored
+	// in the database file, so a read-only connection uses whatever the
+	// writer configured.
+	if !readOnly {
+		if err := exec(`PRAGMA journal_mode = wal;`); err != nil {
+			return fmt.Errorf("enable wal: %w", err)
+		}
 	}
 
 	// Safe in WAL mode. Sync only called when the WAL becomes full.
 	// https://www.sqlite.org/pragma.html#pragma_synchronous
-	if _, err := db.db.Exec(`PRAGMA synchronous = NORMAL;`); err != nil {
-		return nil, fmt.Errorf("foreign keys pragma: %w", err)
+	if err := exec(`PRAGMA synchronous = NORMAL;`); err != nil {
+		return fmt.Errorf("synchronous pragma: %w", err)
 	}
 
 	// Enable foreign key constraints.
-	if _, err := db.db.Exec(`PRAGMA foreign_keys = ON;`); err != nil {
-		return nil, fmt.Errorf("foreign keys pragma: %w", err)
+	if err := exec(`PRAGMA foreign_keys = ON;`); err != nil {
+		return fmt.Errorf("foreign keys pragma: %w", err)
 	}
 
 	// Busy timeout waits for queries to finish if there is an active lock.
-	if _, err := db.db.Exec(`PRAGMA busy_timeout = 5000;`); err != nil {
-		return nil, fmt.Errorf("foreign keys pragma: %w", err)
+	if err := exec(`PRAGMA busy_timeout = 5000;`); err != nil {
+		return fmt.Errorf("busy timeout pragma: %w", err)
 	}
 
 	// Disable auto checkpointing when replication is enabled. This prevents other
 	// processes from checkpointing before litesteams has a chance to replicate
 	// the WAL file.
-	if os.Getenv("LITESTREAM_ACCESS_KEY") != "" {
-		if _, err := db.db.Exec(`PRAGMA wal_autocheckpoint = 0;`); err != nil {
-			return nil, fmt.Errorf("foreign keys pragma: %w", err)
+	if db.replication {
+		if err := exec(`PRAGMA wal_autocheckpoint = 0;`); err != nil {
+			return fmt.Errorf("wal autocheckpoint pragma: %w", err)
 		}
 	}
 
-	if err := db.migrate(); err != nil {
-		return nil, fmt.Errorf("migrate: %w", err)
+	return nil
+}
+
+// Warmup opens and pings n connections up front so the pool is primed before
+// serving traffic, rather than the first requests paying to connect. n is
+// capped at the maximum number of open connections. The read replica pool,
+// if there is one, is warmed up the same way.
+func (db *Sqlite) Warmup(n int) error {
+	if err := db.warmup(db.db, n); err != nil {
+		return fmt.Errorf("warmup: %w", err)
 	}
+	if db.replica != nil {
+		if err := db.warmup(db.replica, n); err != nil {
+			return fmt.Errorf("warmup read replica: %w", err)
+		}
+	}
+	return nil
+}
 
-	return db, nil
+func (db *Sqlite) warmup(pool *sqlx.DB, n int) error {
+	if max := pool.Stats().MaxOpenConnections; max > 0 && n > max {
+		n = max
+	}
+
+	// Hold every connection until all of them are open, otherwise the pool
+	// would hand the same idle connection back each time.
+	conns := make([]*sql.Conn, 0, n)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+
+	for i := 0; i < n; i++ {
+		conn, err := pool.Conn(db.ctx)
+		if err != nil {
+			return err
+		}
+		conns = append(conns, conn)
+
+		if err := conn.PingContext(db.ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Ready reports whether the database is able to serve requests. It returns
+// ErrContextCanceled if ctx was done before the database answered.
+//
+// After a write has failed because storage was full, Ready tries a small
+// write of its own with SelfTest, and returns ErrStorageFull until that
+// succeeds. An unready instance gets no traffic, so it can't rely on a request
+// making a successful write to notice that space has been freed.
+func (db *Sqlite) Ready(ctx context.Context) error {
+	if err := db.db.PingContext(ctx); err != nil {
+		return mapError(err)
+	}
+
+	if atomic.LoadInt32(&db.storageFull) == 1 {
+		if err := db.SelfTest(ctx); err != nil {
+			if errors.Is(err, ErrStorageFull) {
+				return ErrStorageFull
+			}
+			return err
+		}
+		atomic.StoreInt32(&db.storageFull, 0)
+	}
+	return nil
+}
+
+// WaitReady pings the database every interval until a ping succeeds or ctx is
+// done, in which case the last ping error is returned, wrapped with the
+// context error. It returns as soon as the first ping succeeds, and logs each
+// failed attempt at Debug level.
+func (db *Sqlite) WaitReady(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for attempt := 1; ; attempt++ {
+		err := db.db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		db.logger.Debug("database not ready (attempt %d): %s", attempt, err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database not ready: %w: %s", ctx.Err(), err)
+		case <-ticker.C:
+		}
+	}
 }
 
-// Close closes the database connection.
+// Close closes the database connection. It is safe to call Close more than
+// once; calls after the first return nil.
 func (db *Sqlite) Close() error {
-	// Close database.
-	if db != nil {
+	if db == nil {
+		return nil
+	}
+
+	var err error
+	db.closeOnce.Do(func() {
 		// Cancel background context.
 		db.cancel()
-		return db.db.Close()
-	}
-	return nil
+		if db.replica != nil {
+			db.replica.Close()
+		}
+		db.immediateMu.Lock()
+		if db.immediate != nil {
+			db.immediate.Close()
+		}
+		db.immediateMu.Unlock()
+		err = db.db.Close()
+	})
+	return err
 }
 
 // migrate sets up migration tracking and executes pending migration files.
@@ -95,55 +399,544 @@ func (db *Sqlite) Close() error {
 // is not re-executed. Migrations run in a transaction to prevent partial
 // migrations.
 func (db *Sqlite) migrate() error {
+	return db.MigrateWithProgress(nil)
+}
+
+// MigrateWithProgress executes pending migration files like migrate, calling
+// progress before each file is executed with its name, its zero-based index
+// among the pending files, and the number of pending files. progress only
+// observes the run; a panic inside it is recovered and ignored.
+func (db *Sqlite) MigrateWithProgress(progress func(name string, index, total int)) error {
+	unlock, err := db.lockMigrations()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// Ensure the 'migrations' table exists so we don't duplicate migrations.
-	if _, err := db.db.Exec(`CREATE TABLE IF NOT EXISTS migrations (name TEXT PRIMARY KEY);`); err != nil {
-		return fmt.Errorf("cannot create migrations table: %w", err)
+	// It may need altering, so this happens under the lock.
+	if err := db.ensureMigrationsTable(); err != nil {
+		return err
 	}
 
-	names, err := fs.Glob(migrationFS, "migration/*.sql")
+	names, err := migrationNames(db.migrationFS)
 	if err != nil {
 		return err
 	}
-	sort.Strings(names)
+
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+	for _, name := range names {
+		if !applied[name] {
+			pending = append(pending, name)
+		}
+	}
+
+	start := time.Now()
+	count := 0
 
 	// Loop over all migration files and execute them in order.
+	for i, name := range pending {
+		notifyProgress(progress, name, i, len(pending))
+
+		ok, err := db.migrateFile(name)
+		if err != nil {
+			return fmt.Errorf("migration error: name=%q err=%w", name, err)
+		}
+		if ok {
+			count++
+		}
+	}
+
+	db.logMigrationSummary(count, len(names)-count, time.Since(start))
+	return nil
+}
+
+// logMigrationSummary logs how many migrations a run applied, how many it
+// skipped because they had already been applied, and how long it took.
+func (db *Sqlite) logMigrationSummary(applied, skipped int, duration time.Duration) {
+	db.logger.WithFields(leveledlog.Fields{
+		"applied":  applied,
+		"skipped":  skipped,
+		"duration": duration.String(),
+	}).Info("migrations complete")
+}
+
+// ensureMigrationsTable creates the 'migrations' table, so we don't duplicate
+// migrations, and adds the applied_at column to tables created before it
+// existed. Migrations recorded before then have a NULL applied_at. It must be
+// called with the migration lock held, so that instances starting together
+// don't both try to add the column.
+func (db *Sqlite) ensureMigrationsTable() error {
+	if _, err := db.db.Exec(`CREATE TABLE IF NOT EXISTS migrations (name TEXT PRIMARY KEY, applied_at INTEGER);`); err != nil {
+		return fmt.Errorf("cannot create migrations table: %w", err)
+	}
+
+	var n int
+	if err := db.db.Get(&n, `SELECT COUNT(*) FROM pragma_table_info('migrations') WHERE name = 'applied_at'`); err != nil {
+		return err
+	}
+	if n == 0 {
+		if _, err := db.db.Exec(`ALTER TABLE migrations ADD COLUMN applied_at INTEGER;`); err != nil {
+			return fmt.Errorf("cannot add applied_at to migrations table: %w", err)
+		}
+	}
+	return nil
+}
+
+// MigrationInfo describes an applied migration.
+type MigrationInfo struct {
+	Name      string
+	AppliedAt time.Time
+}
+
+// MigrationsSince returns the migrations applied at or after t, oldest first,
+// e.g. to log the schema changes that went out with a deploy. applied_at is
+// recorded to the second, so t is truncated to the second too, and migrations
+// applied in the same second as t are included. Migrations applied before
+// applied_at was recorded are never included.
+func (db *Sqlite) MigrationsSince(t time.Time) ([]MigrationInfo, error) {
+	var rows []struct {
+		Name      string `db:"name"`
+		AppliedAt int64  `db:"applied_at"`
+	}
+
+	err := db.db.SelectContext(db.ctx, &rows, `
+		SELECT name, applied_at FROM migrations
+		WHERE applied_at >= ?
+		ORDER BY applied_at, name`, t.Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]MigrationInfo, len(rows))
+	for i, row := range rows {
+		infos[i] = MigrationInfo{Name: row.Name, AppliedAt: time.Unix(row.AppliedAt, 0)}
+	}
+	return infos, nil
+}
+
+func notifyProgress(progress func(name string, index, total int), name string, index, total int) {
+	if progress == nil {
+		return
+	}
+	defer func() {
+		_ = recover()
+	}()
+	progress(name, index, total)
+}
+
+// appliedMigrations returns the set of migration names recorded in the
+// 'migrations' table.
+func (db *Sqlite) appliedMigrations() (map[string]bool, error) {
+	var names []string
+	if err := db.db.Select(&names, `SELECT name FROM migrations`); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[string]bool, len(names))
 	for _, name := range names {
-		if err := db.migrateFile(name); err != nil {
+		applied[name] = true
+	}
+	return applied, nil
+}
+
+// MigrateTo executes pending migration files in order up to and including the
+// target version. The version is the numeric prefix of a migration file name
+// (e.g. "00001" or "1"), or the full file name itself.
+//
+// An error is returned if the target does not match any migration file, or if
+// a migration newer than the target has already been applied.
+func (db *Sqlite) MigrateTo(version string) error {
+	unlock, err := db.lockMigrations()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := db.ensureMigrationsTable(); err != nil {
+		return err
+	}
+
+	target, err := parseMigrationVersion(version)
+	if err != nil {
+		return err
+	}
+
+	names, err := migrationNames(db.migrationFS)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, name := range names {
+		v, err := parseMigrationVersion(name)
+		if err != nil {
 			return fmt.Errorf("migration error: name=%q err=%w", name, err)
 		}
+		if v == target {
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("unknown migration version: %q", version)
+	}
+
+	// Refuse to continue if the database is already ahead of the target.
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return err
+	}
+	for name := range applied {
+		v, err := parseMigrationVersion(name)
+		if err != nil {
+			continue
+		}
+		if v > target {
+			return fmt.Errorf("database is ahead of target version %q: %q already applied", version, name)
+		}
+	}
+
+	start := time.Now()
+	count, skipped := 0, 0
+
+	for _, name := range names {
+		v, _ := parseMigrationVersion(name)
+		if v > target {
+			break
+		}
+		ok, err := db.migrateFile(name)
+		if err != nil {
+			return fmt.Errorf("migration error: name=%q err=%w", name, err)
+		}
+		if ok {
+			count++
+		} else {
+			skipped++
+		}
 	}
+
+	db.logMigrationSummary(count, skipped, time.Since(start))
 	return nil
 }
 
-// migrate runs a single migration file within a transaction. On success, the
-// migration file name is saved to the "migrations" table to prevent re-running.
-func (db *Sqlite) migrateFile(name string) error {
-	tx, err := db.db.Begin()
+// MigrationMode controls what New does about pending migrations.
+type MigrationMode int
+
+const (
+	// MigrationAuto applies pending migrations. It is the default.
+	MigrationAuto MigrationMode = iota
+
+	// MigrationCheck fails with a *PendingMigrationsError if any migrations
+	// are pending, for deployments that run migrations as a separate step.
+	MigrationCheck
+
+	// MigrationSkip leaves migrations alone.
+	MigrationSkip
+)
+
+func (m MigrationMode) String() string {
+	switch m {
+	case MigrationAuto:
+		return "auto"
+	case MigrationCheck:
+		return "check"
+	case MigrationSkip:
+		return "skip"
+	default:
+		return ""
+	}
+}
+
+// PendingMigrationsError is returned by New in MigrationCheck mode when the
+// database has migrations that haven't been applied.
+type PendingMigrationsError struct {
+	Names []string
+}
+
+func (e *PendingMigrationsError) Error() string {
+	return fmt.Sprintf("database has %d pending migrations: %s", len(e.Names), strings.Join(e.Names, ", "))
+}
+
+// checkMigrations returns a *PendingMigrationsError if any migrations haven't
+// been applied. Unlike migrate, it doesn't write to the database.
+func (db *Sqlite) checkMigrations() error {
+	names, err := migrationNames(db.migrationFS)
 	if err != nil {
 		return err
 	}
+
+	var tables int
+	if err := db.db.Get(&tables, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'migrations'`); err != nil {
+		return err
+	}
+
+	applied := map[string]bool{}
+	if tables > 0 {
+		applied, err = db.appliedMigrations()
+		if err != nil {
+			return err
+		}
+	}
+
+	var pending []string
+	for _, name := range names {
+		if !applied[name] {
+			pending = append(pending, name)
+		}
+	}
+	if len(pending) > 0 {
+		return &PendingMigrationsError{Names: pending}
+	}
+	return nil
+}
+
+// MigrationNames returns the names of the embedded migration files in the
+// order they are executed. It does not touch any database state.
+func MigrationNames() ([]string, error) {
+	return migrationNames(migrationFS)
+}
+
+// migrationNames returns the migration file names in fsys in lexigraphical
+// order.
+func migrationNames(fsys fs.FS) ([]string, error) {
+	names, err := fs.Glob(fsys, "migration/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// parseMigrationVersion returns the numeric prefix of a migration file name,
+// e.g. "migration/00001_users.sql" returns 1. A bare version such as "00001"
+// is also accepted.
+func parseMigrationVersion(name string) (int, error) {
+	base := path.Base(name)
+	prefix := base
+	if i := strings.IndexFunc(base, func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+		prefix = base[:i]
+	}
+
+	v, err := strconv.Atoi(prefix)
+	if err != nil {
+		return 0, fmt.Errorf("invalid migration version: %q", name)
+	}
+	return v, nil
+}
+
+// migrateFile runs a single migration file and logs how long it took, with a
+// warning if it was slower than the configured threshold. It reports false if
+// the migration had already been run.
+func (db *Sqlite) migrateFile(name string) (bool, error) {
+	start := time.Now()
+
+	applied, err := db.applyMigrationFile(name)
+	if err != nil || !applied {
+		return false, err
+	}
+
+	duration := time.Since(start)
+	logger := db.migrationLogger(name).WithFields(leveledlog.Fields{"duration": duration.String()})
+	logger.Info("migration applied")
+
+	if db.slowMigrationThreshold > 0 && duration > db.slowMigrationThreshold {
+		logger.Warning("slow migration, exceeding %s", db.slowMigrationThreshold)
+	}
+
+	return true, nil
+}
+
+// migrationLogger returns a logger that tags entries with the migration
+// file name, so a run's output can be filtered by migration.
+func (db *Sqlite) migrationLogger(name string) leveledlog.Interface {
+	return db.logger.WithFields(leveledlog.Fields{"migration": name})
+}
+
+// applyMigrationFile runs a single migration file within a transaction. On
+// success, the migration file name is saved to the "migrations" table to
+// prevent re-running. It reports false if the migration had already been run.
+func (db *Sqlite) applyMigrationFile(name string) (bool, error) {
+	buf, err := fs.ReadFile(db.migrationFS, name)
+	if err != nil {
+		return false, err
+	}
+
+	// Editors on some platforms save files with a UTF-8 byte order mark,
+	// which SQLite doesn't accept.
+	script := strings.TrimSpace(strings.TrimPrefix(string(buf), "\uFEFF"))
+
+	// Render the template before anything else inspects the script, so the
+	// rendered SQL is what gets executed and recorded.
+	script, err = db.renderMigration(name, script)
+	if err != nil {
+		return false, err
+	}
+
+	if size, ok := batchDirective(script); ok {
+		if size <= 0 {
+			size = db.migrationBatchSize
+		}
+		return db.migrateFileBatched(name, script, size)
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return false, err
+	}
 	defer tx.Rollback()
 
 	// Ensure migration has not already been run.
 	var n int
 	if err := tx.QueryRow(`SELECT COUNT(*) FROM migrations WHERE name = ?`, name).Scan(&n); err != nil {
-		return err
+		return false, err
 	} else if n != 0 {
-		return nil
+		return false, nil
 	}
 
-	if buf, err := fs.ReadFile(migrationFS, name); err != nil {
-		return err
-	} else if _, err := tx.Exec(string(buf)); err != nil {
-		return err
+	db.warnIfEmpty(name, script)
+
+	if _, err := tx.Exec(script); err != nil {
+		return false, err
 	}
 
 	// Insert record into migrations to prevent re-running migration.
-	if _, err := tx.Exec(`INSERT INTO migrations (name) VALUES (?)`, name); err != nil {
-		return err
+	if _, err := tx.Exec(`INSERT INTO migrations (name, applied_at) VALUES (?, ?)`, name, time.Now().Unix()); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// warnIfEmpty logs a warning if a migration has no statements, e.g. because it
+// only contains comments, so it isn't silently recorded as applied.
+func (db *Sqlite) warnIfEmpty(name, script string) {
+	if len(splitStatements(script)) == 0 {
+		db.migrationLogger(name).Warning("migration contains no statements")
+	}
+}
+
+var batchDirectiveRE = regexp.MustCompile(`^--\s*batch(?:\s+(\d+))?\s*$`)
+
+// batchDirective reports whether a migration is marked for batched execution
+// with a "-- batch" or "-- batch <size>" comment line before its first
+// statement. The returned size is zero if none was given.
+func batchDirective(script string) (int, bool) {
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			return 0, false
+		}
+		if m := batchDirectiveRE.FindStringSubmatch(line); m != nil {
+			size, _ := strconv.Atoi(m[1])
+			return size, true
+		}
+	}
+	return 0, false
+}
+
+var templateDirectiveRE = regexp.MustCompile(`^--\s*template\s*$`)
+
+// templateDirective reports whether a migration is marked for rendering with a
+// "-- template" comment line before its first statement.
+func templateDirective(script string) bool {
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			return false
+		}
+		if templateDirectiveRE.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderMigration executes script as a text/template with the data given to
+// WithMigrationTemplateData, if templating applies to it. A placeholder with
+// no matching key is an error rather than rendering as "<no value>".
+func (db *Sqlite) renderMigration(name, script string) (string, error) {
+	if !db.templateAll && !templateDirective(script) {
+		return script, nil
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(script)
+	if err != nil {
+		return "", fmt.Errorf("migration %s: %w", name, err)
 	}
 
-	fmt.Printf("migration success: %s\n", name)
+	var b strings.Builder
+	if err := tmpl.Execute(&b, db.templateData); err != nil {
+		return "", fmt.Errorf("migration %s: %w", name, err)
+	}
+	return b.String(), nil
+}
+
+// migrateFileBatched runs a migration marked with the batch directive. Its
+// statements are executed in separate transactions of up to size statements
+// each, committing between batches to release the write lock so other writers
+// aren't blocked during large backfills.
+//
+// This sacrifices the all-or-nothing atomicity of a normal migration: if a
+// batch fails, earlier batches stay committed and the migration is not
+// recorded, so it runs again from the start next time. Batched migrations
+// must therefore be safe to re-run.
+func (db *Sqlite) migrateFileBatched(name, script string, size int) (bool, error) {
+	var n int
+	if err := db.db.QueryRow(`SELECT COUNT(*) FROM migrations WHERE name = ?`, name).Scan(&n); err != nil {
+		return false, err
+	} else if n != 0 {
+		return false, nil
+	}
+
+	db.warnIfEmpty(name, script)
+
+	if size < 1 {
+		size = 1
+	}
+
+	statements := splitStatements(script)
+
+	for start := 0; start < len(statements) || start == 0; start += size {
+		end := start + size
+		if end > len(statements) {
+			end = len(statements)
+		}
+
+		tx, err := db.db.Begin()
+		if err != nil {
+			return false, err
+		}
+
+		for _, stmt := range statements[start:end] {
+			if _, err := tx.Exec(stmt); err != nil {
+				tx.Rollback()
+				return false, err
+			}
+		}
+
+		// Record the migration as part of the final batch.
+		if end == len(statements) {
+			if _, err := tx.Exec(`INSERT INTO migrations (name, applied_at) VALUES (?, ?)`, name, time.Now().Unix()); err != nil {
+				tx.Rollback()
+				return false, err
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return false, err
+		}
+	}
 
-	return tx.Commit()
+	return true, nil
 }
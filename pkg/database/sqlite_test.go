@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// newTestDB opens a migrated database in a temporary directory, closed when
+// the test finishes.
+func newTestDB(t *testing.T, opts ...Option) *Sqlite {
+	t.Helper()
+
+	db, err := New(filepath.Join(t.TempDir(), "test.db"), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestCloseTwice(t *testing.T) {
+	db, err := New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("first Close: %s", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Errorf("second Close: got %s; want nil", err)
+	}
+}
+
+func TestNewClosesPoolOnError(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migration/00000_broken.sql": {Data: []byte("CREATE TABLE")},
+	}
+
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 10; i++ {
+		db, err := New(filepath.Join(t.TempDir(), "test.db"), WithMigrationFS(fsys))
+		if err == nil {
+			db.Close()
+			t.Fatal("got nil error; want the migration error")
+		}
+	}
+
+	// Each leaked pool would leave its connection opener goroutine running.
+	checkGoroutines(t, before)
+}
+
+// checkGoroutines fails the test if, after giving closed pools a moment to
+// stop their background goroutines, noticeably more than want are running.
+func checkGoroutines(t *testing.T, want int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		got := runtime.NumGoroutine()
+		if got <= want+2 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Errorf("got %d goroutines after failed opens; want about %d", got, want)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReadReplicaPragmas(t *testing.T) {
+	dir := t.TempDir()
+	replicaPath := filepath.Join(dir, "replica.db")
+
+	replica, err := New(replicaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replica.Close()
+
+	db := newTestDB(t, WithReadReplica(replicaPath))
+
+	var timeout int
+	if err := db.replica.Get(&timeout, `PRAGMA busy_timeout`); err != nil {
+		t.Fatal(err)
+	}
+	if timeout != 5000 {
+		t.Errorf("got replica busy_timeout %d; want 5000", timeout)
+	}
+}
+
+func TestNewClosesPoolOnReplicaError(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing", "replica.db")
+
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 10; i++ {
+		db, err := New(filepath.Join(t.TempDir(), "test.db"), WithReadReplica(missing))
+		if err == nil {
+			db.Close()
+			t.Fatal("got nil error; want the replica open error")
+		}
+	}
+
+	checkGoroutines(t, before)
+}
+
+func TestPragmasOnEveryConnection(t *testing.T) {
+	db := newTestDB(t)
+
+	// Hold several connections at once so the pool has to open new ones.
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		conn, err := db.db.Connx(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		var timeout, foreignKeys int
+		if err := conn.GetContext(ctx, &timeout, `PRAGMA busy_timeout`); err != nil {
+			t.Fatal(err)
+		}
+		if err := conn.GetContext(ctx, &foreignKeys, `PRAGMA foreign_keys`); err != nil {
+			t.Fatal(err)
+		}
+		if timeout != 5000 || foreignKeys != 1 {
+			t.Errorf("connection %d: got busy_timeout %d, foreign_keys %d; want 5000, 1", i, timeout, foreignKeys)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var createTriggerRE = regexp.MustCompile(`(?i)^CREATE\s+(TEMP\s+|TEMPORARY\s+)?TRIGGER\b`)
+
+// splitStatements splits a SQL script into individual statements on
+// semicolons, ignoring semicolons inside string literals, quoted identifiers,
+// comments and CREATE TRIGGER bodies. Statements containing only whitespace
+// or comments are dropped.
+func splitStatements(script string) []string {
+	statements, rest := splitComplete(script)
+	if rest != "" {
+		statements = append(statements, strings.TrimSpace(rest))
+	}
+	return statements
+}
+
+// splitComplete is like splitStatements, but returns any text after the last
+// complete statement separately and untrimmed, so a script can be split as it
+// is read.
+func splitComplete(script string) (statements []string, rest string) {
+	var (
+		current   strings.Builder
+		code      strings.Builder // current statement without comments
+		word      strings.Builder
+		depth     int // nesting of BEGIN/CASE ... END within a trigger
+		inTrigger bool
+	)
+
+	// endWord is called at the end of each bare word to track trigger bodies.
+	endWord := func() {
+		if word.Len() == 0 {
+			return
+		}
+		if !inTrigger && createTriggerRE.MatchString(strings.TrimSpace(code.String())) {
+			inTrigger = true
+		}
+		if inTrigger {
+			switch strings.ToUpper(word.String()) {
+			case "BEGIN", "CASE":
+				depth++
+			case "END":
+				depth--
+			}
+		}
+		word.Reset()
+	}
+
+	flush := func() {
+		if strings.TrimSpace(code.String()) != "" {
+			statements = append(statements, strings.TrimSpace(current.String()))
+		}
+		current.Reset()
+		code.Reset()
+		depth = 0
+		inTrigger = false
+	}
+
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			endWord()
+			end := i
+			for end < len(runes) && runes[end] != '\n' {
+				end++
+			}
+			current.WriteString(string(runes[i:end]))
+			i = end - 1
+
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			endWord()
+			end := i + 2
+			for end < len(runes) && !(runes[end] == '/' && runes[end-1] == '*' && end > i+2) {
+				end++
+			}
+			if end < len(runes) {
+				end++
+			}
+			current.WriteString(string(runes[i:end]))
+			i = end - 1
+
+		case r == '\'' || r == '"' || r == '`' || r == '[':
+			endWord()
+			closing := r
+			if r == '[' {
+				closing = ']'
+			}
+			end := i + 1
+			for end < len(runes) {
+				if runes[end] == closing {
+					// A doubled quote is an escaped quote.
+					if closing != ']' && end+1 < len(runes) && runes[end+1] == closing {
+						end += 2
+						continue
+					}
+					break
+				}
+				end++
+			}
+			if end < len(runes) {
+				end++
+			}
+			current.WriteString(string(runes[i:end]))
+			code.WriteString(string(runes[i:end]))
+			i = end - 1
+
+		case r == ';':
+			endWord()
+			current.WriteRune(r)
+			code.WriteRune(r)
+			if !inTrigger || depth <= 0 {
+				flush()
+			}
+
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			word.WriteRune(r)
+			current.WriteRune(r)
+			code.WriteRune(r)
+
+		default:
+			endWord()
+			current.WriteRune(r)
+			code.WriteRune(r)
+		}
+	}
+	endWord()
+
+	if strings.TrimSpace(code.String()) != "" {
+		rest = current.String()
+	}
+	return statements, rest
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+type contextKey string
+
+const (
+	trackerContextKey = contextKey("queryTracker")
+	counterContextKey = contextKey("queryCounter")
+)
+
+// QueryTracker records whether a context's deadline expired while one of the
+// Sqlite query methods was running, so timeout middleware can tell a slow
+// database apart from a slow handler. It is best effort: queries run through
+// DB or a Tx aren't tracked.
+type QueryTracker struct {
+	mu       sync.Mutex
+	timedOut string
+}
+
+// NewQueryTracker returns a copy of ctx carrying a new QueryTracker.
+func NewQueryTracker(ctx context.Context) (context.Context, *QueryTracker) {
+	t := &QueryTracker{}
+	return context.WithValue(ctx, trackerContextKey, t), t
+}
+
+// TimedOutQuery returns the query that was running when the deadline expired,
+// if any.
+func (t *QueryTracker) TimedOutQuery() (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.timedOut, t.timedOut != ""
+}
+
+// trackTimeout records query on the tracker in ctx, if any, when ctx's
+// deadline has expired.
+func trackTimeout(ctx context.Context, query string) {
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return
+	}
+	t, ok := ctx.Value(trackerContextKey).(*QueryTracker)
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timedOut == "" {
+		t.timedOut = query
+	}
+}
+
+// QueryCounter counts the queries run with a context, e.g. to spot a request
+// that makes one query per row of a result. Like QueryTracker it counts the
+// Sqlite query methods only, not queries run through DB or a Tx.
+type QueryCounter struct {
+	n int64
+}
+
+// NewQueryCounter returns a copy of ctx carrying a new QueryCounter.
+func NewQueryCounter(ctx context.Context) (context.Context, *QueryCounter) {
+	c := &QueryCounter{}
+	return context.WithValue(ctx, counterContextKey, c), c
+}
+
+// Count returns the number of queries counted so far.
+func (c *QueryCounter) Count() int64 {
+	return atomic.LoadInt64(&c.n)
+}
+
+// countQuery increments the counter in ctx, if any.
+func countQuery(ctx context.Context) {
+	if c, ok := ctx.Value(counterContextKey).(*QueryCounter); ok {
+		atomic.AddInt64(&c.n, 1)
+	}
+}
@@ -0,0 +1,253 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Tx is a transaction passed to WithTx callbacks. It embeds *sqlx.Tx, so all
+// of its methods are available, and keeps a running total of the rows
+// affected by the statements it executes.
+type Tx struct {
+	*sqlx.Tx
+	rowsAffected int64
+}
+
+// RowsAffected returns the total number of rows affected by statements
+// executed through tx so far. Statements whose result doesn't report rows
+// affected don't count towards the total.
+func (tx *Tx) RowsAffected() int64 {
+	return tx.rowsAffected
+}
+
+func (tx *Tx) count(result sql.Result, err error) (sql.Result, error) {
+	if err != nil {
+		return result, mapError(err)
+	}
+	if n, err := result.RowsAffected(); err == nil {
+		tx.rowsAffected += n
+	}
+	return result, nil
+}
+
+func (tx *Tx) Exec(query string, args ...any) (sql.Result, error) {
+	return tx.count(tx.Tx.Exec(query, args...))
+}
+
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return tx.count(tx.Tx.ExecContext(ctx, query, args...))
+}
+
+func (tx *Tx) NamedExec(query string, arg any) (sql.Result, error) {
+	return tx.count(tx.Tx.NamedExec(query, arg))
+}
+
+func (tx *Tx) NamedExecContext(ctx context.Context, query string, arg any) (sql.Result, error) {
+	return tx.count(tx.Tx.NamedExecContext(ctx, query, arg))
+}
+
+const txContextKey = contextKey("tx")
+
+// ContextWithTx returns a copy of ctx carrying tx. The Sqlite query methods
+// run queries made with the returned context within tx instead of on the
+// pool, and WithTx joins tx rather than starting a new transaction, so a group
+// of functions that each take a context can share one transaction without
+// being passed it. WithTxContext starts a transaction and stores it on the
+// context in one step:
+//
+//	err := db.WithTxContext(ctx, func(ctx context.Context) error {
+//		if err := createOrder(ctx, db, order); err != nil {
+//			return err
+//		}
+//		return reserveStock(ctx, db, order.Items)
+//	})
+//
+// Nothing is committed until the transaction that tx belongs to is. Prefer
+// WithTxContext, which always commits or rolls back; a tx begun by hand and
+// never committed silently discards every write made with the context, and
+// holds its connection, and the write lock, until it is rolled back. The
+// context must not be used once the transaction has ended, as queries made
+// with it then fail with sql.ErrTxDone.
+func ContextWithTx(ctx context.Context, tx *Tx) context.Context {
+	return context.WithValue(ctx, txContextKey, tx)
+}
+
+// TxFromContext returns the transaction stored by ContextWithTx, if any.
+func TxFromContext(ctx context.Context) (*Tx, bool) {
+	tx, ok := ctx.Value(txContextKey).(*Tx)
+	return tx, ok
+}
+
+// WithTx runs fn within a transaction, committing if fn returns nil and
+// rolling back if it returns an error or panics. If ctx carries a transaction
+// from ContextWithTx, fn runs within it instead, and committing or rolling
+// back is left to whoever started it.
+func (db *Sqlite) WithTx(ctx context.Context, fn func(tx *Tx) error) error {
+	_, err := db.WithTxCount(ctx, fn)
+	return err
+}
+
+// WithTxContext is like WithTx, but passes fn a copy of ctx carrying the
+// transaction, see ContextWithTx, so that the query methods called with it run
+// within the transaction.
+func (db *Sqlite) WithTxContext(ctx context.Context, fn func(ctx context.Context) error) error {
+	return db.WithTx(ctx, func(tx *Tx) error {
+		return fn(ContextWithTx(ctx, tx))
+	})
+}
+
+// WithTxCount is like WithTx, but also returns the total number of rows
+// affected by the statements executed in the transaction, e.g. for logging
+// "updated N rows" after a backfill.
+func (db *Sqlite) WithTxCount(ctx context.Context, fn func(tx *Tx) error) (int64, error) {
+	if tx, ok := TxFromContext(ctx); ok {
+		before := tx.rowsAffected
+		if err := fn(tx); err != nil {
+			return 0, err
+		}
+		return tx.rowsAffected - before, nil
+	}
+
+	return db.runTx(ctx, db.db, fn)
+}
+
+// runTx runs fn within a new transaction begun on pool.
+func (db *Sqlite) runTx(ctx context.Context, pool *sqlx.DB, fn func(tx *Tx) error) (int64, error) {
+	sqlxTx, err := pool.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	tx := &Tx{Tx: sqlxTx}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return 0, fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, db.writeError(err)
+	}
+
+	return tx.rowsAffected, nil
+}
+
+// txRetryBackoff is the wait before the first retry in TxWithRetry, doubled
+// after each further attempt.
+const txRetryBackoff = 10 * time.Millisecond
+
+// TxWithRetry is like WithTx, but if the transaction fails because the
+// database is busy or locked by another connection, it is rolled back and fn
+// is run again from scratch in a new transaction, up to attempts times in
+// total, with a backoff between attempts. fn always runs at least once. Any
+// other error is returned immediately. Retries are logged at Warning level.
+//
+// Since fn may run more than once, it must not have side effects outside the
+// transaction. The transactions are begun with BEGIN IMMEDIATE on a dedicated
+// connection, so the write lock is taken, and waited for up to the
+// busy_timeout, at BEGIN. A deferred transaction would instead fail at once
+// as busy when it first writes if another connection holds the lock, which
+// retrying from scratch can't avoid.
+//
+// If ctx carries a transaction from ContextWithTx, fn runs once within it
+// without retrying, as only whoever started that transaction can run it again
+// from scratch.
+func (db *Sqlite) TxWithRetry(ctx context.Context, attempts int, fn func(tx *Tx) error) error {
+	if _, ok := TxFromContext(ctx); ok {
+		return db.WithTx(ctx, fn)
+	}
+
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	pool, err := db.immediatePool()
+	if err != nil {
+		return err
+	}
+
+	backoff := txRetryBackoff
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		_, err = db.runTx(ctx, pool, fn)
+		if err == nil || !isBusy(err) || attempt == attempts {
+			return err
+		}
+
+		db.logger.Warning("transaction attempt %d of %d failed, retrying in %s: %s", attempt, attempts, backoff, err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		backoff *= 2
+	}
+	return err
+}
+
+// immediatePool returns the pool used by TxWithRetry, opening it on first
+// use. It has a single connection, so writes through it are serialized, and
+// is opened with _txlock=immediate so the driver begins
+// transactions with BEGIN IMMEDIATE. A read-only database never writes, so it
+// uses the primary pool.
+func (db *Sqlite) immediatePool() (*sqlx.DB, error) {
+	if db.readOnly {
+		return db.db, nil
+	}
+
+	db.immediateMu.Lock()
+	defer db.immediateMu.Unlock()
+
+	if db.immediate != nil {
+		return db.immediate, nil
+	}
+	if db.ctx.Err() != nil {
+		return nil, sql.ErrConnDone
+	}
+
+	pool, err := db.open(immediateDSN(db.dsn), false)
+	if err != nil {
+		return nil, err
+	}
+	pool.SetMaxOpenConns(1)
+	pool.SetMaxIdleConns(1)
+
+	db.immediate = pool
+	return pool, nil
+}
+
+// immediateDSN adds _txlock=immediate to dsn.
+func immediateDSN(dsn string) string {
+	if strings.Contains(dsn, "?") {
+		return dsn + "&_txlock=immediate"
+	}
+	return dsn + "?_txlock=immediate"
+}
+
+// isBusy reports whether err is SQLITE_BUSY or SQLITE_LOCKED.
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+}
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func sqliteBusy() error {
+	return sqlite3.Error{Code: sqlite3.ErrBusy}
+}
+
+func TestTxWithRetryAttempts(t *testing.T) {
+	db := newTestDB(t)
+
+	for _, attempts := range []int{-1, 0, 1} {
+		calls := 0
+		err := db.TxWithRetry(context.Background(), attempts, func(tx *Tx) error {
+			calls++
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if calls != 1 {
+			t.Errorf("attempts %d: got %d calls; want 1", attempts, calls)
+		}
+	}
+}
+
+func TestTxWithRetryBeginsImmediate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// A second connection that doesn't wait for locks.
+	other, err := sql.Open("sqlite3", path+"?_busy_timeout=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	err = db.TxWithRetry(context.Background(), 1, func(tx *Tx) error {
+		// fn hasn't written anything, but the write lock is already held.
+		_, err := other.Exec(`BEGIN IMMEDIATE`)
+		if !isBusy(err) {
+			t.Errorf("got %v from another writer; want SQLITE_BUSY", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTxWithRetryRetriesBusy(t *testing.T) {
+	db := newTestDB(t)
+
+	calls := 0
+	err := db.TxWithRetry(context.Background(), 3, func(tx *Tx) error {
+		calls++
+		if calls < 3 {
+			return sqliteBusy()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("got %d calls; want 3", calls)
+	}
+
+	calls = 0
+	want := errors.New("not busy")
+	err = db.TxWithRetry(context.Background(), 3, func(tx *Tx) error {
+		calls++
+		return want
+	})
+	if !errors.Is(err, want) {
+		t.Errorf("got error %v; want %v", err, want)
+	}
+	if calls != 1 {
+		t.Errorf("got %d calls for a non-busy error; want 1", calls)
+	}
+}
+
+func TestTxWithRetryJoinsContextTx(t *testing.T) {
+	db := newTestDB(t)
+
+	var outer *Tx
+	calls := 0
+	err := db.WithTxContext(context.Background(), func(ctx context.Context) error {
+		outer, _ = TxFromContext(ctx)
+		return db.TxWithRetry(ctx, 3, func(tx *Tx) error {
+			calls++
+			if tx != outer {
+				t.Error("got a new transaction; want the one from the context")
+			}
+			return sqliteBusy()
+		})
+	})
+	if !isBusy(err) {
+		t.Errorf("got error %v; want SQLITE_BUSY", err)
+	}
+	if calls != 1 {
+		t.Errorf("got %d calls; want 1", calls)
+	}
+}
+
+func TestWithTxContext(t *testing.T) {
+	db := newTestDB(t)
+
+	if _, err := db.ExecContext(context.Background(), `CREATE TABLE widgets (name TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+
+	want := errors.New("roll back")
+	err := db.WithTxContext(context.Background(), func(ctx context.Context) error {
+		if _, ok := TxFromContext(ctx); !ok {
+			t.Error("got a context without a transaction")
+		}
+		if _, err := db.ExecContext(ctx, `INSERT INTO widgets (name) VALUES ('a')`); err != nil {
+			return err
+		}
+		return want
+	})
+	if !errors.Is(err, want) {
+		t.Fatalf("got error %v; want %v", err, want)
+	}
+
+	// The insert went through the context's transaction, so it was rolled
+	// back with it.
+	var n int
+	if err := db.GetContext(context.Background(), &n, `SELECT COUNT(*) FROM widgets`); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("got %d widgets; want 0", n)
+	}
+}
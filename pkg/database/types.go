@@ -0,0 +1,118 @@
+package database
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// JSON stores a value of type T in a text column as JSON. It can be used as a
+// struct field with the query and exec methods:
+//
+//	type Event struct {
+//		ID      int64                         `db:"id"`
+//		Payload database.JSON[map[string]any] `db:"payload"`
+//	}
+//
+// A NULL column scans to the zero value of T. JSON marshals to and from JSON
+// as V itself, so the same struct can be written straight to a response.
+type JSON[T any] struct {
+	V T
+}
+
+// Value implements driver.Valuer.
+func (j JSON[T]) Value() (driver.Value, error) {
+	buf, err := json.Marshal(j.V)
+	if err != nil {
+		return nil, err
+	}
+	return string(buf), nil
+}
+
+// Scan implements sql.Scanner.
+func (j *JSON[T]) Scan(src any) error {
+	var zero T
+
+	switch v := src.(type) {
+	case nil:
+		j.V = zero
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), &j.V)
+	case []byte:
+		return json.Unmarshal(v, &j.V)
+	default:
+		return fmt.Errorf("database: cannot scan %T into JSON", src)
+	}
+}
+
+func (j JSON[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(j.V)
+}
+
+func (j *JSON[T]) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &j.V)
+}
+
+// TimeFormat is the layout Time is written in. It is UTC without a zone
+// suffix, which SQLite's date and time functions understand and which sorts
+// correctly as text.
+const TimeFormat = "2006-01-02 15:04:05.999999999"
+
+// Time stores a time.Time in a text column in TimeFormat. It reads any of the
+// formats the driver understands, including the output of SQLite's
+// datetime('now') and CURRENT_TIMESTAMP, and Unix seconds from integer
+// columns. Values without a zone are read as UTC.
+//
+// The zero Time is written as NULL, and NULL scans to the zero Time.
+type Time struct {
+	time.Time
+}
+
+// Value implements driver.Valuer.
+func (t Time) Value() (driver.Value, error) {
+	if t.IsZero() {
+		return nil, nil
+	}
+	return t.UTC().Format(TimeFormat), nil
+}
+
+// Scan implements sql.Scanner.
+func (t *Time) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		t.Time = time.Time{}
+		return nil
+	case time.Time:
+		// The driver parses columns declared as DATETIME, DATE or TIMESTAMP
+		// itself.
+		t.Time = v.UTC()
+		return nil
+	case int64:
+		t.Time = time.Unix(v, 0).UTC()
+		return nil
+	case string:
+		return t.parse(v)
+	case []byte:
+		return t.parse(string(v))
+	default:
+		return fmt.Errorf("database: cannot scan %T into Time", src)
+	}
+}
+
+func (t *Time) parse(s string) error {
+	s = strings.TrimSuffix(s, "Z")
+
+	for _, layout := range sqlite3.SQLiteTimestampFormats {
+		if parsed, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			t.Time = parsed.UTC()
+			return nil
+		}
+	}
+
+	return fmt.Errorf("database: cannot parse %q as Time", s)
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// autoVacuumIncremental is the value of PRAGMA auto_vacuum for
+// auto_vacuum=INCREMENTAL.
+const autoVacuumIncremental = 2
+
+// checkIncrementalVacuum warns if auto_vacuum=INCREMENTAL, which pragmas sets
+// on each connection, didn't take effect because the database predates it.
+func (db *Sqlite) checkIncrementalVacuum(ctx context.Context) error {
+	var mode int
+	if err := db.db.GetContext(ctx, &mode, `PRAGMA auto_vacuum;`); err != nil {
+		return fmt.Errorf("auto vacuum pragma: %w", err)
+	}
+	if mode != autoVacuumIncremental {
+		db.logger.Warning("auto_vacuum is not incremental: the database already has tables, so it needs a full VACUUM first")
+	}
+
+	return nil
+}
+
+// IncrementalVacuum removes up to pages free pages from the database file,
+// truncating it by that much. A pages value of zero or less removes every free
+// page. The database must use auto_vacuum=INCREMENTAL, see
+// WithIncrementalAutoVacuum; otherwise it does nothing.
+//
+// It holds the write lock while it runs, so keep pages small enough for it to
+// finish quickly.
+func (db *Sqlite) IncrementalVacuum(ctx context.Context, pages int) error {
+	query := fmt.Sprintf(`PRAGMA incremental_vacuum(%d);`, pages)
+
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+	defer observeQuery(ctx, query, time.Now())
+
+	// The pragma frees one page for each step of the statement, so the rows
+	// have to be read to the end for it to complete.
+	rows, err := db.db.QueryContext(ctx, query)
+	if err != nil {
+		return db.writeError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+	}
+
+	return db.writeError(rows.Err())
+}
+
+// StartIncrementalVacuum calls IncrementalVacuum with pages every interval in a
+// background goroutine, so the file doesn't keep growing after large deletes.
+// It stops when the database is closed.
+//
+// Only the first call starts a vacuum goroutine; later calls do nothing. A
+// non-positive interval disables it, and doesn't count as the first call.
+func (db *Sqlite) StartIncrementalVacuum(interval time.Duration, pages int) {
+	if interval <= 0 {
+		return
+	}
+
+	db.vacuumOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-db.ctx.Done():
+					return
+				case <-ticker.C:
+					if err := db.IncrementalVacuum(db.ctx, pages); err != nil {
+						// Closing the database cancels the context mid-vacuum.
+						if db.ctx.Err() != nil {
+							return
+						}
+						db.logger.Warning("incremental vacuum failed: %s", err)
+					}
+				}
+			}
+		}()
+	})
+}
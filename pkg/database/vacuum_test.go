@@ -0,0 +1,25 @@
+package database
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestStartIncrementalVacuum(t *testing.T) {
+	db := newTestDB(t, WithIncrementalAutoVacuum())
+
+	before := runtime.NumGoroutine()
+
+	db.StartIncrementalVacuum(0, 100)
+	db.StartIncrementalVacuum(-time.Second, 100)
+	if after := runtime.NumGoroutine(); after != before {
+		t.Errorf("non-positive interval: got %d goroutines; want %d", after, before)
+	}
+
+	db.StartIncrementalVacuum(time.Hour, 100)
+	db.StartIncrementalVacuum(time.Hour, 100)
+	if after := runtime.NumGoroutine(); after != before+1 {
+		t.Errorf("started twice: got %d goroutines; want %d", after, before+1)
+	}
+}
@@ -0,0 +1,232 @@
+package leveledlog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// QueuePolicy decides what an async logger does with an entry when its queue
+// is full.
+type QueuePolicy int
+
+const (
+	// BlockOnFull makes the logging call wait for space in the queue. No
+	// entries are lost, but a slow writer slows down the callers, which
+	// under sustained overload means the request handlers.
+	BlockOnFull QueuePolicy = iota
+
+	// DropOnFull discards the entry and counts it in Dropped. Callers never
+	// wait on the writer, at the cost of losing entries during bursts.
+	DropOnFull
+)
+
+// WithAsync writes entries from a background goroutine through a queue of size
+// entries, so logging calls don't wait on a slow writer. policy decides what
+// happens when the queue is full. Call Close before exiting to write out the
+// queued entries; Fatal does so itself.
+func WithAsync(size int, policy QueuePolicy) Option {
+	return func(l *Logger) {
+		l.asyncSize = size
+		l.asyncPolicy = policy
+	}
+}
+
+// WithQueueHighWater makes an async logger write a warning, synchronously so
+// it isn't itself queued, when its queue holds n or more entries. The warning
+// is repeated only after the queue has dropped back below n. Zero disables
+// the warning, which is the default.
+func WithQueueHighWater(n int) Option {
+	return func(l *Logger) {
+		l.asyncHighWater = n
+	}
+}
+
+// WithFlushInterval makes an async logger batch entries in memory and write
+// them out every d, or sooner when a buffer fills, instead of writing each
+// entry as it is dequeued. This trades up to d of delay for fewer writes.
+// Close writes out whatever is buffered. It has no effect without WithAsync.
+func WithFlushInterval(d time.Duration) Option {
+	return func(l *Logger) {
+		l.asyncFlush = d
+	}
+}
+
+type asyncEntry struct {
+	out   io.Writer
+	entry []byte
+}
+
+// asyncQueue is shared by a logger and its children.
+type asyncQueue struct {
+	entries   chan asyncEntry
+	policy    QueuePolicy
+	highWater int
+
+	dropped uint64
+	warned  int32
+
+	// closeMu guards closed, and is held for reading while sending so the
+	// channel isn't closed under a sender.
+	closeMu sync.RWMutex
+	closed  bool
+	done    chan struct{}
+}
+
+// startAsync starts the writer goroutine if WithAsync was given. It is called
+// by the constructors once the options have been applied.
+func (l *Logger) startAsync() {
+	if l.asyncSize <= 0 {
+		return
+	}
+
+	l.async = &asyncQueue{
+		entries:   make(chan asyncEntry, l.asyncSize),
+		policy:    l.asyncPolicy,
+		highWater: l.asyncHighWater,
+		done:      make(chan struct{}),
+	}
+
+	go l.drain()
+}
+
+func (l *Logger) drain() {
+	q := l.async
+	defer close(q.done)
+
+	if l.asyncFlush > 0 {
+		l.drainBuffered(l.asyncFlush)
+		return
+	}
+
+	for e := range q.entries {
+		l.write(e.out, e.entry)
+		q.resetWarning()
+	}
+}
+
+// drainBuffered is drain for WithFlushInterval. Entries are collected in a
+// buffer per writer, which is written out when it fills, on every tick, and
+// when the queue is closed.
+func (l *Logger) drainBuffered(interval time.Duration) {
+	q := l.async
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	buffers := make(map[io.Writer]*bufio.Writer)
+	flush := func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		for _, bw := range buffers {
+			bw.Flush()
+		}
+	}
+
+	for {
+		select {
+		case e, ok := <-q.entries:
+			if !ok {
+				flush()
+				return
+			}
+
+			bw, ok := buffers[e.out]
+			if !ok {
+				bw = bufio.NewWriter(e.out)
+				buffers[e.out] = bw
+			}
+
+			// Hold mu while buffering, since a full buffer is written out
+			// immediately.
+			l.mu.Lock()
+			bw.Write(e.entry)
+			l.mu.Unlock()
+
+			q.resetWarning()
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// resetWarning re-arms the high-water warning once the queue has dropped back
+// below the mark.
+func (q *asyncQueue) resetWarning() {
+	if q.highWater > 0 && len(q.entries) < q.highWater {
+		atomic.StoreInt32(&q.warned, 0)
+	}
+}
+
+// enqueue queues an entry, reporting false if the queue has been closed, in
+// which case the caller writes the entry itself.
+func (l *Logger) enqueue(out io.Writer, entry []byte) bool {
+	q := l.async
+
+	q.closeMu.RLock()
+	defer q.closeMu.RUnlock()
+
+	if q.closed {
+		return false
+	}
+
+	if q.highWater > 0 && len(q.entries) >= q.highWater && atomic.CompareAndSwapInt32(&q.warned, 0, 1) {
+		message := fmt.Sprintf("log queue above high-water mark: %d of %d entries", len(q.entries), cap(q.entries))
+		l.write(l.format(LevelWarning, message))
+	}
+
+	e := asyncEntry{out: out, entry: entry}
+
+	if q.policy == DropOnFull {
+		select {
+		case q.entries <- e:
+		default:
+			atomic.AddUint64(&q.dropped, 1)
+		}
+		return true
+	}
+
+	q.entries <- e
+	return true
+}
+
+// QueueDepth returns the number of entries waiting to be written by an async
+// logger. It is always zero for a synchronous logger.
+func (l *Logger) QueueDepth() int {
+	if l.async == nil {
+		return 0
+	}
+	return len(l.async.entries)
+}
+
+// Dropped returns the number of entries discarded by an async logger with the
+// DropOnFull policy.
+func (l *Logger) Dropped() uint64 {
+	if l.async == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&l.async.dropped)
+}
+
+// Close stops an async logger after writing out the queued and buffered
+// entries. Entries logged after Close are written synchronously. It does
+// nothing for a synchronous logger, and is safe to call more than once.
+func (l *Logger) Close() error {
+	q := l.async
+	if q == nil {
+		return nil
+	}
+
+	q.closeMu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.entries)
+	}
+	q.closeMu.Unlock()
+
+	<-q.done
+	return nil
+}
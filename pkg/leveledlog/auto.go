@@ -0,0 +1,60 @@
+package leveledlog
+
+import (
+	"io"
+	"os"
+)
+
+// WithJSON forces JSON output on or off, overriding the choice made by
+// NewAuto.
+func WithJSON(enabled bool) Option {
+	return func(l *Logger) {
+		l.useJSON = enabled
+	}
+}
+
+// WithColorize forces colorized text output on or off, overriding the choice
+// made by NewAuto, including the NO_COLOR and TERM=dumb checks.
+func WithColorize(enabled bool) Option {
+	return func(l *Logger) {
+		l.colorize = enabled
+	}
+}
+
+// NewAuto returns a logger that writes colorized text when out is a terminal,
+// and JSON otherwise, on the basis that non-interactive output (e.g. under
+// Docker or systemd) is consumed by machines. Pass WithJSON to force either
+// mode.
+//
+// Colors are left off, while keeping text output, if the NO_COLOR environment
+// variable is set to a non-empty value (see https://no-color.org) or TERM is
+// "dumb", as in some CI terminals. Pass WithColorize to force them either way.
+func NewAuto(out io.Writer, minLevel Level, opts ...Option) *Logger {
+	tty := isTerminal(out)
+
+	opts = append([]Option{WithJSON(!tty)}, opts...)
+
+	return NewLogger(out, minLevel, tty && colorsAllowed(), opts...)
+}
+
+// colorsAllowed reports whether the environment permits ANSI colors.
+func colorsAllowed() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return os.Getenv("TERM") != "dumb"
+}
+
+// isTerminal reports whether w is a character device such as a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,17 @@
+package leveledlog
+
+import "context"
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying logger.
+func NewContext(ctx context.Context, logger Interface) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger stored in ctx by NewContext, or nil if there
+// is none.
+func FromContext(ctx context.Context) Interface {
+	logger, _ := ctx.Value(contextKey{}).(Interface)
+	return logger
+}
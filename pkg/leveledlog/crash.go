@@ -0,0 +1,55 @@
+package leveledlog
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+	"time"
+)
+
+// maxCrashFileSize is the size above which the crash file is rotated to
+// <path>.1, replacing any previous rotation, before a new entry is appended.
+const maxCrashFileSize = 1 << 20
+
+// WithCrashFile also appends Fatal entries, with a full stack trace, to the
+// file at path. The file survives even if the process output is lost, e.g.
+// with a crashed container. It is capped by rotating it to <path>.1 once it
+// grows past 1MB, so at most two crash files are kept.
+func WithCrashFile(path string) Option {
+	return func(l *Logger) {
+		l.crashFile = path
+	}
+}
+
+// WriteCrash appends a timestamped entry with message and stack to the crash
+// file set by WithCrashFile. If stack is nil the current goroutine's stack is
+// used. It does nothing if there is no crash file.
+func (l *Logger) WriteCrash(message string, stack []byte) error {
+	if l.crashFile == "" {
+		return nil
+	}
+	if stack == nil {
+		stack = debug.Stack()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if info, err := os.Stat(l.crashFile); err == nil && info.Size() > maxCrashFileSize {
+		if err := os.Rename(l.crashFile, l.crashFile+".1"); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(l.crashFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "=== %s %s\n%s\n", time.Now().UTC().Format(time.RFC3339), message, stack)
+	if err != nil {
+		return err
+	}
+	return f.Sync()
+}
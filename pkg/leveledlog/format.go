@@ -0,0 +1,135 @@
+package leveledlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Formatter turns a log entry into the bytes written to the output. The
+// returned bytes are written in a single call and must include any
+// terminator, such as a trailing newline. fields have already been redacted,
+// and trace is empty unless a stack trace is to be included.
+//
+// Formatters are called concurrently and must not retain fields.
+type Formatter interface {
+	Format(level Level, time time.Time, message string, fields map[string]any, trace string) []byte
+}
+
+// TextFormatter writes key=value text, the default for NewLogger.
+type TextFormatter struct {
+	// Colors colorizes the level. Nil disables colors.
+	Colors ColorScheme
+
+	// FoldTrace writes the trace as an escaped field rather than on the
+	// following lines.
+	FoldTrace bool
+}
+
+func (f TextFormatter) Format(level Level, t time.Time, message string, fields map[string]any, trace string) []byte {
+	levelField := fmt.Sprintf("level=%q", level)
+	if code, ok := f.Colors[level]; ok {
+		levelField = "\x1b[" + code + "m" + levelField + "\x1b[0m"
+	}
+
+	line := fmt.Sprintf("%s time=%q message=%q", levelField, t.Format(time.RFC3339), message)
+
+	fields = flattenFields(fields)
+	for _, key := range sortedKeys(fields) {
+		line += " " + key + "=" + logfmtValue(fields[key])
+	}
+
+	if trace != "" {
+		if f.FoldTrace {
+			line += fmt.Sprintf(" trace=%q", trace)
+		} else {
+			line += fmt.Sprintf("\n%s", trace)
+		}
+	}
+
+	return []byte(line + "\n")
+}
+
+// logfmtValue formats a field value so the line stays parseable as logfmt.
+// Strings are always quoted. Other values, such as errors, are quoted only if
+// their text is empty or contains spaces, equals signs, quotes or control
+// characters, which are escaped.
+func logfmtValue(v any) string {
+	if s, ok := v.(string); ok {
+		return strconv.Quote(s)
+	}
+
+	s := fmt.Sprint(v)
+	if s == "" || strings.IndexFunc(s, needsQuote) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func needsQuote(r rune) bool {
+	return r <= ' ' || r == '=' || r == '"' || r == 0x7f || r == utf8.RuneError
+}
+
+// JSONFormatter writes one JSON object per line, the default for
+// NewJSONLogger. It always produces a single line: json.Marshal escapes the
+// newlines in the message, fields and trace.
+type JSONFormatter struct{}
+
+func (JSONFormatter) Format(level Level, t time.Time, message string, fields map[string]any, trace string) []byte {
+	return []byte(jsonLine(level, t, message, fields, trace) + "\n")
+}
+
+func jsonLine(level Level, t time.Time, message string, fields map[string]any, trace string) string {
+	aux := struct {
+		Level   string `json:"level"`
+		Time    string `json:"time"`
+		Message string `json:"message"`
+		Trace   string `json:"trace,omitempty"`
+	}{
+		Level:   level.String(),
+		Time:    t.UTC().Format(time.RFC3339),
+		Message: message,
+		Trace:   trace,
+	}
+
+	var line []byte
+
+	line, err := json.Marshal(aux)
+	if err != nil {
+		return fmt.Sprintf("%s: unable to marshal log message: %s", LevelError.String(), err.Error())
+	}
+
+	if len(fields) == 0 {
+		return string(line)
+	}
+
+	// Fields can't overwrite the standard keys. Each field is marshalled on
+	// its own so a value that can't be marshalled is replaced with a
+	// placeholder rather than losing the whole entry.
+	extra := make(map[string]json.RawMessage, len(fields))
+	for k, v := range fields {
+		switch k {
+		case "level", "time", "message", "trace":
+			continue
+		}
+		js, err := json.Marshal(v)
+		if err != nil {
+			js, _ = json.Marshal(fmt.Sprintf("<unmarshalable: %s>", err))
+		}
+		extra[k] = js
+	}
+
+	extraLine, err := json.Marshal(extra)
+	if err != nil {
+		return fmt.Sprintf("%s: unable to marshal log fields: %s", LevelError.String(), err.Error())
+	}
+	if len(extraLine) <= 2 {
+		return string(line)
+	}
+
+	// Splice the fields into the object after the standard keys.
+	return string(line[:len(line)-1]) + "," + string(extraLine[1:])
+}
@@ -0,0 +1,132 @@
+package leveledlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONLineIsSingleRecord(t *testing.T) {
+	modes := []struct {
+		name      string
+		mode      TraceMode
+		wantTrace bool
+	}{
+		{"default", TraceDefault, true},
+		{"fold", TraceFold, true},
+		{"omit", TraceOmit, false},
+	}
+
+	for _, m := range modes {
+		var buf bytes.Buffer
+		l := NewJSONLogger(&buf, LevelAll, WithTraceMode(m.mode))
+
+		l.WithFields(Fields{"detail": "first\nsecond"}).Error(errors.New("line one\nline two"))
+
+		out := buf.String()
+		if !strings.HasSuffix(out, "\n") {
+			t.Fatalf("%s: got %q; want a trailing newline", m.name, out)
+		}
+		if n := strings.Count(out, "\n"); n != 1 {
+			t.Errorf("%s: got %d newlines; want 1", m.name, n)
+		}
+
+		dec := json.NewDecoder(strings.NewReader(out))
+		var entry map[string]any
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatalf("%s: %s", m.name, err)
+		}
+		if dec.More() {
+			t.Errorf("%s: got more than one record in %q", m.name, out)
+		}
+
+		if entry["message"] != "line one\nline two" {
+			t.Errorf("%s: got message %q; want %q", m.name, entry["message"], "line one\nline two")
+		}
+		_, gotTrace := entry["trace"]
+		if gotTrace != m.wantTrace {
+			t.Errorf("%s: got trace %t; want %t", m.name, gotTrace, m.wantTrace)
+		}
+	}
+}
+
+type failingMarshaler struct{}
+
+func (failingMarshaler) MarshalJSON() ([]byte, error) {
+	return nil, errors.New("always fails")
+}
+
+func TestJSONUnmarshalableField(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewJSONLogger(&buf, LevelAll)
+
+	l.WithFields(Fields{
+		"channel": make(chan int),
+		"failing": failingMarshaler{},
+		"user":    "alice",
+	}).Info("message")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("got invalid JSON %q: %s", buf.String(), err)
+	}
+
+	if entry["message"] != "message" {
+		t.Errorf("got message %v; want message", entry["message"])
+	}
+	if entry["user"] != "alice" {
+		t.Errorf("got user %v; want alice", entry["user"])
+	}
+	for _, key := range []string{"channel", "failing"} {
+		s, _ := entry[key].(string)
+		if !strings.HasPrefix(s, "<unmarshalable: ") {
+			t.Errorf("got %s %v; want an <unmarshalable: ...> placeholder", key, entry[key])
+		}
+	}
+}
+
+func TestTextFormatterQuotesValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{"plain", "alice", "alice"},
+		{"spaces", "connection refused: foo", "connection refused: foo"},
+		{"equals", "a=b", "a=b"},
+		{"quotes", `say "hi"`, `say "hi"`},
+		{"newlines", "first\nsecond", "first\nsecond"},
+		{"empty", "", ""},
+		{"error with spaces", errors.New("no such host"), "no such host"},
+		{"number", 42, "42"},
+	}
+
+	for _, tt := range tests {
+		line := string(TextFormatter{}.Format(LevelInfo, time.Now(), "message", map[string]any{"value": tt.value}, ""))
+
+		if n := strings.Count(line, "\n"); n != 1 {
+			t.Errorf("%s: got %d newlines in %q; want 1", tt.name, n, line)
+		}
+
+		raw := strings.TrimSuffix(line[strings.Index(line, " value=")+len(" value="):], "\n")
+		got := raw
+		if strings.HasPrefix(raw, `"`) {
+			var err error
+			got, err = strconv.Unquote(raw)
+			if err != nil {
+				t.Errorf("%s: got unparseable value %s: %s", tt.name, raw, err)
+				continue
+			}
+		} else if strings.ContainsAny(raw, " =\"\n") {
+			t.Errorf("%s: got unquoted value %s; want it quoted", tt.name, raw)
+		}
+
+		if got != tt.want {
+			t.Errorf("%s: got value %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package leveledlog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"example.com/pkg/leveledlog"
+)
+
+// spyLogger records entries instead of writing them, showing that types other
+// than *Logger can implement Interface.
+type spyLogger struct {
+	fields  leveledlog.Fields
+	entries *[]string
+}
+
+var _ leveledlog.Interface = spyLogger{}
+
+func (s spyLogger) record(level leveledlog.Level, message string) {
+	*s.entries = append(*s.entries, fmt.Sprintf("%s %s %v", level, message, s.fields))
+}
+
+func (s spyLogger) Enabled(leveledlog.Level) bool { return true }
+func (s spyLogger) Debug(format string, v ...any) {
+	s.record(leveledlog.LevelDebug, fmt.Sprintf(format, v...))
+}
+func (s spyLogger) Info(format string, v ...any) {
+	s.record(leveledlog.LevelInfo, fmt.Sprintf(format, v...))
+}
+func (s spyLogger) Warning(format string, v ...any) {
+	s.record(leveledlog.LevelWarning, fmt.Sprintf(format, v...))
+}
+func (s spyLogger) Error(err error)                    { s.record(leveledlog.LevelError, err.Error()) }
+func (s spyLogger) Fatal(err error)                    { s.record(leveledlog.LevelFatal, err.Error()) }
+func (s spyLogger) FatalCode(code int, err error)      { s.record(leveledlog.LevelFatal, err.Error()) }
+func (s spyLogger) LogError(err error) error           { s.Error(err); return err }
+func (s spyLogger) LogErrorf(f string, v ...any) error { return s.LogError(fmt.Errorf(f, v...)) }
+
+func (s spyLogger) WithFields(fields leveledlog.Fields) leveledlog.Interface {
+	merged := leveledlog.Fields{}
+	for k, v := range s.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return spyLogger{fields: merged, entries: s.entries}
+}
+
+func (s spyLogger) Named(component string) leveledlog.Interface {
+	return s.WithFields(leveledlog.Fields{"component": component})
+}
+
+func TestSpyLogger(t *testing.T) {
+	var entries []string
+	var logger leveledlog.Interface = spyLogger{entries: &entries}
+
+	logger.WithFields(leveledlog.Fields{"user": "alice"}).Info("logged in")
+
+	want := "INFO logged in map[user:alice]"
+	if len(entries) != 1 || entries[0] != want {
+		t.Errorf("got entries %q; want [%q]", entries, want)
+	}
+}
+
+func TestNamedAccumulates(t *testing.T) {
+	var buf bytes.Buffer
+	leveledlog.NewJSONLogger(&buf, leveledlog.LevelAll).Named("http").Named("auth").Info("message")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["component"] != "http.auth" {
+		t.Errorf("got component %v; want http.auth", entry["component"])
+	}
+
+	buf.Reset()
+	leveledlog.NewLogger(&buf, leveledlog.LevelAll, false).Named("http").Named("auth").Info("message")
+	if !strings.Contains(buf.String(), `component="http.auth"`) {
+		t.Errorf("got %q; want component=\"http.auth\"", buf.String())
+	}
+}
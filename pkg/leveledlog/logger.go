@@ -1,11 +1,12 @@
 package leveledlog
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"runtime/debug"
+	"sort"
+	"sync"
 	"time"
 )
 
@@ -13,6 +14,7 @@ type Level int8
 
 const (
 	LevelAll Level = iota
+	LevelDebug
 	LevelInfo
 	LevelWarning
 	LevelError
@@ -22,6 +24,8 @@ const (
 
 func (l Level) String() string {
 	switch l {
+	case LevelDebug:
+		return "DEBUG"
 	case LevelInfo:
 		return "INFO"
 	case LevelWarning:
@@ -35,96 +39,416 @@ func (l Level) String() string {
 	}
 }
 
+// Interface is the set of logging methods implemented by *Logger. Code that
+// wants to swap implementations, such as a spy logger in tests, can depend on
+// it instead of the concrete type.
+type Interface interface {
+	Enabled(level Level) bool
+	Debug(format string, v ...any)
+	Info(format string, v ...any)
+	Warning(format string, v ...any)
+	Error(err error)
+	Fatal(err error)
+	FatalCode(code int, err error)
+	LogError(err error) error
+	LogErrorf(format string, v ...any) error
+	WithFields(fields Fields) Interface
+	Named(component string) Interface
+}
+
+var _ Interface = (*Logger)(nil)
+
+// ColorScheme maps levels to the ANSI SGR parameters used to colorize them in
+// text mode, e.g. "31" for red or "1;33" for bold yellow. Levels missing from
+// the scheme are not colorized.
+type ColorScheme map[Level]string
+
+var (
+	ColorsDefault = ColorScheme{
+		LevelDebug:   "90",
+		LevelInfo:    "32",
+		LevelWarning: "33",
+		LevelError:   "31",
+		LevelFatal:   "35",
+	}
+
+	ColorsHighContrast = ColorScheme{
+		LevelDebug:   "97",
+		LevelInfo:    "1;92",
+		LevelWarning: "1;93",
+		LevelError:   "1;91",
+		LevelFatal:   "1;97;41",
+	}
+
+	// ColorsColorblind avoids distinguishing levels by red and green.
+	ColorsColorblind = ColorScheme{
+		LevelDebug:   "90",
+		LevelInfo:    "34",
+		LevelWarning: "33",
+		LevelError:   "1;35",
+		LevelFatal:   "1;97;45",
+	}
+)
+
+// Fields are key/value pairs attached to every entry written by a logger
+// returned from WithFields.
+//
+// Values that are maps with string keys, such as Fields, or structs are nested
+// objects: they are written as nested JSON objects in JSON mode and flattened
+// to dotted keys in text mode, so
+//
+//	logger.WithFields(leveledlog.Fields{"http": leveledlog.Fields{"method": "GET", "status": 200}})
+//
+// writes {"http":{"method":"GET","status":200}} or http.method="GET"
+// http.status=200. Struct fields are named as encoding/json names them.
+// Structs that implement error, fmt.Stringer, json.Marshaler or
+// encoding.TextMarshaler, such as time.Time, are written as single values.
+type Fields map[string]any
+
 type Logger struct {
-	out      io.Writer
-	minLevel Level
-	useJSON  bool
-	colorize bool
+	out                io.Writer
+	levelOut           map[Level]io.Writer
+	minLevel           Level
+	stackTraceMinLevel Level
+	useJSON            bool
+	colorize           bool
+	colors             ColorScheme
+	traceMode          TraceMode
+	fields             Fields
+	name               string
+	crashFile          string
+	customFormatter    Formatter
+	exit               func(code int)
+
+	// mu serializes writes so each entry reaches out in a single Write call,
+	// and is shared with child loggers.
+	mu *sync.Mutex
+
+	asyncSize      int
+	asyncPolicy    QueuePolicy
+	asyncHighWater int
+	asyncFlush     time.Duration
+	async          *asyncQueue
+}
+
+// TraceMode controls how stack traces are written.
+type TraceMode int
+
+const (
+	// TraceDefault writes traces on the lines following the entry in text
+	// mode, and as an escaped "trace" field in JSON mode.
+	TraceDefault TraceMode = iota
+
+	// TraceFold writes traces as a single escaped field in both modes, so
+	// every entry is exactly one line.
+	TraceFold
+
+	// TraceOmit never writes traces.
+	TraceOmit
+)
+
+// Option configures a Logger created by NewLogger or NewJSONLogger.
+type Option func(*Logger)
+
+// WithStackTraceMinLevel sets the minimum level at which a stack trace is
+// included with a log entry. The default is LevelError.
+func WithStackTraceMinLevel(level Level) Option {
+	return func(l *Logger) {
+		l.stackTraceMinLevel = level
+	}
+}
+
+// WithTraceMode sets how stack traces are written. The default is
+// TraceDefault.
+func WithTraceMode(mode TraceMode) Option {
+	return func(l *Logger) {
+		l.traceMode = mode
+	}
 }
 
-func NewLogger(out io.Writer, minLevel Level, colorize bool) *Logger {
-	return &Logger{
-		out:      out,
-		minLevel: minLevel,
-		colorize: colorize,
+// WithLevelWriter sends entries at the given level to w instead of the
+// logger's default writer.
+func WithLevelWriter(level Level, w io.Writer) Option {
+	return func(l *Logger) {
+		if l.levelOut == nil {
+			l.levelOut = make(map[Level]io.Writer)
+		}
+		l.levelOut[level] = w
 	}
 }
 
-func NewJSONLogger(out io.Writer, minLevel Level) *Logger {
-	return &Logger{
-		out:      out,
-		minLevel: minLevel,
-		useJSON:  true,
+// WithFormatter sets the formatter used to write entries, replacing the
+// built-in text or JSON formatter. Options that only affect the built-in
+// formatters, such as WithColors, are then ignored.
+func WithFormatter(f Formatter) Option {
+	return func(l *Logger) {
+		l.customFormatter = f
 	}
 }
 
+// WithHostInfo adds hostname and pid fields to every entry, to tell replicas
+// apart when their logs are aggregated into one stream. Both are resolved
+// once, when the logger is created.
+func WithHostInfo() Option {
+	return func(l *Logger) {
+		hostname, _ := os.Hostname()
+
+		fields := make(Fields, len(l.fields)+2)
+		for k, v := range l.fields {
+			fields[k] = v
+		}
+		fields["hostname"] = hostname
+		fields["pid"] = os.Getpid()
+		l.fields = fields
+	}
+}
+
+// WithExitFunc replaces os.Exit as the function called by Fatal and FatalCode,
+// e.g. so tests can observe the exit code.
+func WithExitFunc(exit func(code int)) Option {
+	return func(l *Logger) {
+		l.exit = exit
+	}
+}
+
+// WithColors sets the color scheme used when colorize is enabled. It can be one
+// of the presets or a custom scheme. The default is ColorsDefault.
+func WithColors(scheme ColorScheme) Option {
+	return func(l *Logger) {
+		l.colors = scheme
+	}
+}
+
+func NewLogger(out io.Writer, minLevel Level, colorize bool, opts ...Option) *Logger {
+	l := &Logger{
+		out:                out,
+		minLevel:           minLevel,
+		stackTraceMinLevel: LevelError,
+		colorize:           colorize,
+		colors:             ColorsDefault,
+		mu:                 &sync.Mutex{},
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	l.startAsync()
+
+	return l
+}
+
+// NewStdLogger returns a text logger that follows Unix conventions: INFO and
+// WARNING entries go to stdout, while ERROR and FATAL entries go to stderr so
+// they can be redirected separately. Containerised deployments that collect a
+// single stream should use NewJSONLogger instead.
+func NewStdLogger(minLevel Level, colorize bool, opts ...Option) *Logger {
+	opts = append([]Option{
+		WithLevelWriter(LevelError, os.Stderr),
+		WithLevelWriter(LevelFatal, os.Stderr),
+	}, opts...)
+
+	return NewLogger(os.Stdout, minLevel, colorize, opts...)
+}
+
+func NewJSONLogger(out io.Writer, minLevel Level, opts ...Option) *Logger {
+	l := &Logger{
+		out:                out,
+		minLevel:           minLevel,
+		stackTraceMinLevel: LevelError,
+		useJSON:            true,
+		mu:                 &sync.Mutex{},
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	l.startAsync()
+
+	return l
+}
+
+// Enabled reports whether an entry at level would be written. It can be used to
+// guard expensive work that is only needed to build a log message:
+//
+//	if logger.Enabled(leveledlog.LevelDebug) {
+//		logger.Debug("state: %s", dumpState())
+//	}
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.minLevel
+}
+
+// The level methods check Enabled before formatting, so messages below the
+// threshold are discarded without allocating.
+
+func (l *Logger) Debug(format string, v ...any) {
+	if !l.Enabled(LevelDebug) {
+		return
+	}
+	message := fmt.Sprintf(format, v...)
+	l.print(LevelDebug, message)
+}
+
 func (l *Logger) Info(format string, v ...any) {
+	if !l.Enabled(LevelInfo) {
+		return
+	}
 	message := fmt.Sprintf(format, v...)
 	l.print(LevelInfo, message)
 }
 
 func (l *Logger) Warning(format string, v ...any) {
+	if !l.Enabled(LevelWarning) {
+		return
+	}
 	message := fmt.Sprintf(format, v...)
 	l.print(LevelWarning, message)
 }
 
 func (l *Logger) Error(err error) {
+	if !l.Enabled(LevelError) {
+		return
+	}
 	l.print(LevelError, err.Error())
 }
 
+// LogError logs err at Error level and returns it, for use as
+// `return logger.LogError(err)`.
+func (l *Logger) LogError(err error) error {
+	l.Error(err)
+	return err
+}
+
+// LogErrorf logs a new formatted error at Error level and returns it.
+func (l *Logger) LogErrorf(format string, v ...any) error {
+	err := fmt.Errorf(format, v...)
+	l.Error(err)
+	return err
+}
+
+// WithFields returns a child logger that adds fields to every entry, on top of
+// any fields already set on l. The child is a *Logger, returned as Interface
+// so that other implementations can satisfy Interface too.
+func (l *Logger) WithFields(fields Fields) Interface {
+	return l.withFields(fields)
+}
+
+func (l *Logger) withFields(fields Fields) *Logger {
+	child := *l
+	child.fields = make(Fields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		child.fields[k] = v
+	}
+	for k, v := range fields {
+		child.fields[k] = v
+	}
+	return &child
+}
+
+// Named returns a child logger that tags every entry with a component field,
+// e.g. component="db". Names accumulate, so logger.Named("http").Named("auth")
+// tags entries with component="http.auth". Like WithFields, the child is a
+// *Logger returned as Interface.
+func (l *Logger) Named(component string) Interface {
+	name := component
+	if l.name != "" {
+		name = l.name + "." + component
+	}
+
+	child := l.withFields(Fields{"component": name})
+	child.name = name
+	return child
+}
+
+// Exit codes for FatalCode. Supervisors such as systemd can use them to react
+// differently to each kind of failure, e.g. not restarting on a configuration
+// error that a restart won't fix.
+const (
+	ExitGeneric  = 1
+	ExitConfig   = 2
+	ExitDatabase = 3
+)
+
+// Fatal logs err at Fatal level and exits with ExitGeneric.
 func (l *Logger) Fatal(err error) {
+	l.FatalCode(ExitGeneric, err)
+}
+
+// FatalCode logs err at Fatal level and exits with code, using the exit func
+// set by WithExitFunc.
+func (l *Logger) FatalCode(code int, err error) {
 	l.print(LevelFatal, err.Error())
-	os.Exit(1)
+	l.WriteCrash(err.Error(), nil)
+	l.Close()
+
+	exit := l.exit
+	if exit == nil {
+		exit = os.Exit
+	}
+	exit(code)
 }
 
 func (l *Logger) print(level Level, message string) {
-	if level < l.minLevel {
+	if !l.Enabled(level) {
 		return
 	}
 
-	var line string
+	out, entry := l.format(level, message)
 
-	if l.useJSON {
-		line = jsonLine(level, message)
-	} else {
-		line = textLine(level, message, l.colorize)
+	if l.async != nil && l.enqueue(out, entry) {
+		return
 	}
 
-	fmt.Fprintln(l.out, line)
+	l.write(out, entry)
 }
 
-func textLine(level Level, message string, colorize bool) string {
-	line := fmt.Sprintf("level=%q time=%q message=%q", level, time.Now().Format(time.RFC3339), message)
+// format returns the formatted entry and the writer it should go to.
+func (l *Logger) format(level Level, message string) (io.Writer, []byte) {
+	var trace string
+	if level >= l.stackTraceMinLevel && l.traceMode != TraceOmit {
+		trace = string(debug.Stack())
+	}
+
+	entry := l.formatter().Format(level, time.Now(), message, redact(l.fields), trace)
 
-	if level >= LevelError {
-		line += fmt.Sprintf("\n%s", string(debug.Stack()))
+	out := l.out
+	if w, ok := l.levelOut[level]; ok {
+		out = w
 	}
 
-	return line
+	return out, entry
 }
 
-func jsonLine(level Level, message string) string {
-	aux := struct {
-		Level   string `json:"level"`
-		Time    string `json:"time"`
-		Message string `json:"message"`
-		Trace   string `json:"trace,omitempty"`
-	}{
-		Level:   level.String(),
-		Time:    time.Now().UTC().Format(time.RFC3339),
-		Message: message,
+// formatter returns the formatter set by WithFormatter, or else the built-in
+// formatter for the logger's mode.
+func (l *Logger) formatter() Formatter {
+	if l.customFormatter != nil {
+		return l.customFormatter
+	}
+	if l.useJSON {
+		return JSONFormatter{}
 	}
 
-	if level >= LevelError {
-		aux.Trace = string(debug.Stack())
+	f := TextFormatter{FoldTrace: l.traceMode == TraceFold}
+	if l.colorize {
+		f.Colors = l.colors
 	}
+	return f
+}
 
-	var line []byte
+func (l *Logger) write(out io.Writer, entry []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-	line, err := json.Marshal(aux)
-	if err != nil {
-		return fmt.Sprintf("%s: unable to marshal log message: %s", LevelError.String(), err.Error())
-	}
+	out.Write(entry)
+}
 
-	return string(line)
+func sortedKeys(fields Fields) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
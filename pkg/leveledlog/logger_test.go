@@ -0,0 +1,90 @@
+package leveledlog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStackTraceMinLevel(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold Level
+		want      map[Level]bool
+	}{
+		{"warning", LevelWarning, map[Level]bool{LevelInfo: false, LevelWarning: true, LevelError: true}},
+		{"error", LevelError, map[Level]bool{LevelInfo: false, LevelWarning: false, LevelError: true}},
+		{"fatal", LevelFatal, map[Level]bool{LevelInfo: false, LevelWarning: false, LevelError: false, LevelFatal: true}},
+	}
+
+	for _, tt := range tests {
+		for _, json := range []bool{false, true} {
+			for level, want := range tt.want {
+				var buf bytes.Buffer
+				opts := []Option{WithStackTraceMinLevel(tt.threshold), WithExitFunc(func(int) {})}
+
+				l := NewLogger(&buf, LevelAll, false, opts...)
+				if json {
+					l = NewJSONLogger(&buf, LevelAll, opts...)
+				}
+
+				logAt(l, level)
+
+				// The trace always includes the goroutine header.
+				got := strings.Contains(buf.String(), "goroutine ")
+				if got != want {
+					t.Errorf("%s threshold, json=%t, %s entry: got trace %t; want %t", tt.name, json, level, got, want)
+				}
+			}
+		}
+	}
+}
+
+func logAt(l *Logger, level Level) {
+	switch level {
+	case LevelInfo:
+		l.Info("message")
+	case LevelWarning:
+		l.Warning("message")
+	case LevelError:
+		l.Error(errors.New("message"))
+	case LevelFatal:
+		l.Fatal(errors.New("message"))
+	}
+}
+
+func TestBelowThresholdDoesNotAllocate(t *testing.T) {
+	l := NewLogger(io.Discard, LevelError, false)
+	n := 1234
+
+	allocs := testing.AllocsPerRun(100, func() {
+		l.Debug("debug %d", n)
+		l.Info("info %s", "value")
+		l.Warning("warning")
+	})
+	if allocs != 0 {
+		t.Errorf("got %v allocations; want 0", allocs)
+	}
+}
+
+func BenchmarkBelowThreshold(b *testing.B) {
+	l := NewLogger(io.Discard, LevelError, false)
+	n := 1234
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Info("request %d handled in %s", n, "1ms")
+	}
+}
+
+func BenchmarkBelowThresholdJSON(b *testing.B) {
+	l := NewJSONLogger(io.Discard, LevelError)
+	n := 1234
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Info("request %d handled in %s", n, "1ms")
+	}
+}
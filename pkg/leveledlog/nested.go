@@ -0,0 +1,92 @@
+package leveledlog
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// maxFieldDepth bounds how deeply nested field values are followed.
+const maxFieldDepth = 16
+
+// nestedObject returns the keys and values of v if it is a nested object, and
+// for maps the map's address, which is used to detect cycles.
+func nestedObject(v any) (map[string]any, uintptr, bool) {
+	switch m := v.(type) {
+	case Fields:
+		return m, reflect.ValueOf(m).Pointer(), true
+	case map[string]any:
+		return m, reflect.ValueOf(m).Pointer(), true
+	case error, fmt.Stringer, json.Marshaler, encoding.TextMarshaler:
+		return nil, 0, false
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	switch {
+	case rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String:
+		m := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = iter.Value().Interface()
+		}
+		return m, rv.Pointer(), true
+
+	case rv.Kind() == reflect.Struct:
+		// Going through encoding/json names the fields by their json tags
+		// and rejects cyclic structs.
+		js, err := json.Marshal(v)
+		if err != nil {
+			return nil, 0, false
+		}
+		dec := json.NewDecoder(bytes.NewReader(js))
+		dec.UseNumber()
+		var m map[string]any
+		if err := dec.Decode(&m); err != nil {
+			return nil, 0, false
+		}
+		return m, 0, true
+	}
+
+	return nil, 0, false
+}
+
+// flattenFields returns a copy of fields with nested objects replaced by their
+// values under dotted keys, for text output.
+func flattenFields(fields map[string]any) map[string]any {
+	flat := make(map[string]any, len(fields))
+	for k, v := range fields {
+		flattenField(flat, k, v, nil)
+	}
+	return flat
+}
+
+// flattenField adds v to flat under key. path holds the addresses of the maps
+// v is nested in. A map nested within itself is written as "<cycle>".
+func flattenField(flat map[string]any, key string, v any, path []uintptr) {
+	m, ptr, ok := nestedObject(v)
+	if !ok {
+		flat[key] = v
+		return
+	}
+
+	if len(path) >= maxFieldDepth {
+		flat[key] = "<max depth>"
+		return
+	}
+	for _, p := range path {
+		if ptr != 0 && p == ptr {
+			flat[key] = "<cycle>"
+			return
+		}
+	}
+
+	for k, v := range m {
+		flattenField(flat, key+"."+k, v, append(path, ptr))
+	}
+}
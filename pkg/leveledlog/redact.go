@@ -0,0 +1,90 @@
+package leveledlog
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Redacted replaces the values of redacted fields.
+const Redacted = "***"
+
+// RedactedKeys lists the substrings that mark a field as secret. The value of
+// any field whose key contains one of them, ignoring case, is replaced with
+// Redacted when the entry is written. The same list marks the secret
+// parameters of a database DSN, such as go-sqlite3's _auth_pass or a SQLCipher
+// _pragma_key, see database.RedactDSN.
+var RedactedKeys = []string{
+	"pass",
+	"pwd",
+	"secret",
+	"token",
+	"key",
+	"credential",
+	"private",
+}
+
+// IsRedactedKey reports whether the value of a field named key is redacted.
+func IsRedactedKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range RedactedKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact returns a copy of fields with the secret values replaced, including
+// those in nested objects: Fields, maps with string keys and structs, see
+// nestedObject. A nested object that has secrets is replaced with a redacted
+// map[string]any copy; the others are left as they are.
+func redact(fields Fields) Fields {
+	return redactDepth(fields, 0)
+}
+
+func redactDepth(fields Fields, depth int) Fields {
+	var out Fields
+	set := func(k string, v any) {
+		if out == nil {
+			out = make(Fields, len(fields))
+			for k, v := range fields {
+				out[k] = v
+			}
+		}
+		out[k] = v
+	}
+
+	for k, v := range fields {
+		if IsRedactedKey(k) {
+			set(k, Redacted)
+			continue
+		}
+
+		// The depth limit also stops a map nested within itself.
+		if depth >= maxFieldDepth {
+			continue
+		}
+		switch nested := v.(type) {
+		case Fields:
+			if r := redactDepth(nested, depth+1); !sameMap(r, nested) {
+				set(k, r)
+			}
+		default:
+			if m, _, ok := nestedObject(v); ok {
+				if r := redactDepth(m, depth+1); !sameMap(r, m) {
+					set(k, map[string]any(r))
+				}
+			}
+		}
+	}
+
+	if out == nil {
+		return fields
+	}
+	return out
+}
+
+// sameMap reports whether a and b are the same map, rather than equal ones.
+func sameMap(a, b Fields) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
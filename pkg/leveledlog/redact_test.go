@@ -0,0 +1,65 @@
+package leveledlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type credentials struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+func TestRedactNested(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+	}{
+		{"fields", Fields{"password": "hunter2"}},
+		{"map any", map[string]any{"password": "hunter2"}},
+		{"map string", map[string]string{"password": "hunter2"}},
+		{"struct", credentials{User: "alice", Password: "hunter2"}},
+		{"struct pointer", &credentials{User: "alice", Password: "hunter2"}},
+		{"struct in map", map[string]any{"login": credentials{User: "alice", Password: "hunter2"}}},
+	}
+
+	for _, tt := range tests {
+		for _, json := range []bool{false, true} {
+			var buf bytes.Buffer
+			l := NewLogger(&buf, LevelAll, false)
+			if json {
+				l = NewJSONLogger(&buf, LevelAll)
+			}
+
+			l.WithFields(Fields{"request": tt.value}).Info("message")
+
+			if strings.Contains(buf.String(), "hunter2") {
+				t.Errorf("%s, json=%t: got %q; want the password redacted", tt.name, json, buf.String())
+			}
+			if !strings.Contains(buf.String(), Redacted) {
+				t.Errorf("%s, json=%t: got %q; want %s", tt.name, json, buf.String(), Redacted)
+			}
+		}
+	}
+}
+
+func TestRedactLeavesCleanObjects(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewJSONLogger(&buf, LevelAll)
+
+	l.WithFields(Fields{"user": struct {
+		Name string `json:"name"`
+	}{"alice"}}).Info("message")
+
+	var entry struct {
+		User map[string]string `json:"user"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.User["name"] != "alice" {
+		t.Errorf("got user %v; want name alice", entry.User)
+	}
+}
@@ -0,0 +1,55 @@
+package leveledlog
+
+import "sync"
+
+// RingBuffer is an io.Writer that keeps the most recent entries written to it
+// in memory, dropping the oldest once it is full, e.g. for showing recent logs
+// in a debug UI. Each Write is kept as one entry, which matches the single
+// Write per entry made by a Logger. Add it as a secondary sink with
+// io.MultiWriter:
+//
+//	recent := leveledlog.NewRingBuffer(500)
+//	logger := leveledlog.NewLogger(io.MultiWriter(os.Stdout, recent), leveledlog.LevelInfo, false)
+//
+// It is safe for concurrent use.
+type RingBuffer struct {
+	mu      sync.Mutex
+	entries []string
+	next    int
+	full    bool
+}
+
+// NewRingBuffer returns a RingBuffer holding up to capacity entries.
+func NewRingBuffer(capacity int) *RingBuffer {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &RingBuffer{entries: make([]string, capacity)}
+}
+
+func (rb *RingBuffer) Write(p []byte) (int, error) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.entries[rb.next] = string(p)
+	rb.next = (rb.next + 1) % len(rb.entries)
+	if rb.next == 0 {
+		rb.full = true
+	}
+
+	return len(p), nil
+}
+
+// Snapshot returns a copy of the buffered entries, oldest first.
+func (rb *RingBuffer) Snapshot() []string {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if !rb.full {
+		return append([]string(nil), rb.entries[:rb.next]...)
+	}
+
+	out := make([]string, 0, len(rb.entries))
+	out = append(out, rb.entries[rb.next:]...)
+	return append(out, rb.entries[:rb.next]...)
+}
@@ -5,6 +5,9 @@ import (
 	"net/http"
 )
 
+// ContentTypeJSON is the Content-Type set on JSON responses.
+const ContentTypeJSON = "application/json; charset=utf-8"
+
 func JSON(w http.ResponseWriter, status int, data any) error {
 	return JSONWithHeaders(w, status, data, nil)
 }
@@ -21,7 +24,7 @@ func JSONWithHeaders(w http.ResponseWriter, status int, data any, headers http.H
 		w.Header()[key] = value
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", ContentTypeJSON)
 	w.WriteHeader(status)
 	w.Write(js)
 
@@ -0,0 +1,50 @@
+// Package runtimestats periodically logs goroutine and memory statistics for
+// lightweight runtime observability without a metrics stack.
+package runtimestats
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	"example.com/pkg/leveledlog"
+)
+
+// Start logs runtime statistics every interval in a background goroutine until
+// ctx is cancelled. Reading the memory statistics briefly stops the world, so
+// the interval should be measured in seconds or minutes rather than
+// milliseconds. A non-positive interval disables logging, and Start returns
+// without starting the goroutine.
+func Start(ctx context.Context, logger leveledlog.Interface, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				logStats(logger)
+			}
+		}
+	}()
+}
+
+func logStats(logger leveledlog.Interface) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	logger.WithFields(leveledlog.Fields{
+		"goroutines":     runtime.NumGoroutine(),
+		"heap_alloc":     m.HeapAlloc,
+		"heap_objects":   m.HeapObjects,
+		"sys":            m.Sys,
+		"num_gc":         m.NumGC,
+		"gc_pause_total": time.Duration(m.PauseTotalNs).String(),
+	}).Info("runtime stats")
+}
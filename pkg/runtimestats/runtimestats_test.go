@@ -0,0 +1,27 @@
+package runtimestats
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+
+	"example.com/pkg/leveledlog"
+)
+
+func TestStartNonPositiveInterval(t *testing.T) {
+	logger := leveledlog.NewLogger(io.Discard, leveledlog.LevelAll, false)
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		before := runtime.NumGoroutine()
+
+		// time.NewTicker panics on a non-positive interval, which would crash
+		// the process from the goroutine.
+		Start(context.Background(), logger, interval)
+
+		if after := runtime.NumGoroutine(); after != before {
+			t.Errorf("interval %s: got %d goroutines; want %d", interval, after, before)
+		}
+	}
+}
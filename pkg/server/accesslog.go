@@ -0,0 +1,80 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type AccessLogFormat int
+
+const (
+	// Common is the NCSA Common Log Format:
+	//
+	//	host ident authuser [date] "request" status bytes
+	Common AccessLogFormat = iota
+
+	// Combined is the Common Log Format followed by the referer and user agent.
+	Combined
+)
+
+// AccessLog writes a line for each request to w in the given format. It writes
+// to its own writer, separate from the application logger, so the output can
+// be fed to tooling that expects Apache style access logs.
+func AccessLog(w io.Writer, format AccessLogFormat) func(http.Handler) http.Handler {
+	var mu sync.Mutex
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := newResponseRecorder(rw)
+
+			next.ServeHTTP(rec, r)
+
+			line := accessLogLine(r, rec.status, rec.bytes, start, format)
+
+			mu.Lock()
+			defer mu.Unlock()
+			io.WriteString(w, line)
+		})
+	}
+}
+
+func accessLogLine(r *http.Request, status int, bytes int64, t time.Time, format AccessLogFormat) string {
+	user := "-"
+	if username, _, ok := r.BasicAuth(); ok && username != "" {
+		user = username
+	}
+
+	size := "-"
+	if bytes > 0 {
+		size = strconv.FormatInt(bytes, 10)
+	}
+
+	line := fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s",
+		ClientIP(r),
+		user,
+		t.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method,
+		r.URL.RequestURI(),
+		r.Proto,
+		status,
+		size,
+	)
+
+	if format == Combined {
+		line += fmt.Sprintf(" %q %q", orDash(r.Referer()), orDash(r.UserAgent()))
+	}
+
+	return line + "\n"
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
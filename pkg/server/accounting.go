@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"example.com/pkg/leveledlog"
+)
+
+const clientContextKey = contextKey("client")
+
+// QuotaStore tracks the bytes transferred by each client against its quota.
+// Implementations must be safe for concurrent use.
+type QuotaStore interface {
+	// OverQuota reports whether client has used up its quota.
+	OverQuota(ctx context.Context, client string) (bool, error)
+
+	// Record adds the request and response body sizes of a completed request
+	// to the usage of client.
+	Record(ctx context.Context, client string, bytesIn, bytesOut int64) error
+}
+
+// NewClientContext returns a copy of ctx carrying the identity of the
+// authenticated client, for use by Accounting. It is normally called by the
+// authentication middleware.
+func NewClientContext(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientContextKey, client)
+}
+
+// ClientFromContext returns the client identity stored by NewClientContext, or
+// an empty string if there is none.
+func ClientFromContext(ctx context.Context) string {
+	client, _ := ctx.Value(clientContextKey).(string)
+	return client
+}
+
+// Accounting measures the request and response body bytes of each request from
+// an authenticated client and reports them to store. Requests from a client
+// that is over quota are rejected with a 429 quota_exceeded error.
+//
+// It must run after the middleware that calls NewClientContext. Requests with
+// no client identity are served without accounting. If the store fails, the
+// error is logged against the logger in the request context, if any, and the
+// request is served, so an outage of the store doesn't take the API down.
+func Accounting(store QuotaStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client := ClientFromContext(r.Context())
+			if client == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			over, err := store.OverQuota(r.Context(), client)
+			if err != nil {
+				logAccountingError(r.Context(), "check quota", err)
+			} else if over {
+				WriteAPIError(w, APIError{
+					Code:    "quota_exceeded",
+					Message: "Your quota for this period has been used up",
+					Status:  http.StatusTooManyRequests,
+				})
+				return
+			}
+
+			body := &countingReader{ReadCloser: r.Body}
+			if r.Body != nil {
+				r.Body = body
+			}
+			rec := newResponseRecorder(w)
+
+			next.ServeHTTP(rec, r)
+
+			// The request context is cancelled if the client hung up, but the
+			// bytes were still transferred, so record them regardless.
+			if err := store.Record(context.Background(), client, body.n, rec.bytes); err != nil {
+				logAccountingError(r.Context(), "record usage", err)
+			}
+		})
+	}
+}
+
+func logAccountingError(ctx context.Context, action string, err error) {
+	if logger := leveledlog.FromContext(ctx); logger != nil {
+		logger.Warning("accounting: unable to %s: %s", action, err)
+	}
+}
+
+// countingReader counts the bytes read from a request body.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"example.com/pkg/database"
+	"example.com/pkg/response"
+)
+
+// APIError is the standard error envelope returned to clients:
+//
+//	{"error": {"code": "not_found", "message": "...", "details": {...}}}
+type APIError struct {
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Status  int            `json:"-"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+func (e APIError) Error() string {
+	return e.Message
+}
+
+func NotFound(message string) APIError {
+	return APIError{Code: "not_found", Message: message, Status: http.StatusNotFound}
+}
+
+func BadRequest(message string) APIError {
+	return APIError{Code: "bad_request", Message: message, Status: http.StatusBadRequest}
+}
+
+func Unauthorized(message string) APIError {
+	return APIError{Code: "unauthorized", Message: message, Status: http.StatusUnauthorized}
+}
+
+func MethodNotAllowed(message string) APIError {
+	return APIError{Code: "method_not_allowed", Message: message, Status: http.StatusMethodNotAllowed}
+}
+
+func Conflict(message string) APIError {
+	return APIError{Code: "conflict", Message: message, Status: http.StatusConflict}
+}
+
+func TooManyRequests() APIError {
+	return APIError{
+		Code:    "rate_limited",
+		Message: "You have exceeded the rate limit, please slow down and try again",
+		Status:  http.StatusTooManyRequests,
+	}
+}
+
+func ServiceUnavailable() APIError {
+	return APIError{
+		Code:    "service_unavailable",
+		Message: "The server is temporarily unable to handle your request",
+		Status:  http.StatusServiceUnavailable,
+	}
+}
+
+func InternalError() APIError {
+	return APIError{
+		Code:    "internal_error",
+		Message: "The server encountered a problem and could not process your request",
+		Status:  http.StatusInternalServerError,
+	}
+}
+
+// APIErrorFrom maps err to an APIError. An APIError anywhere in the chain is
+// returned as is, the database sentinel errors are mapped to their matching
+// envelope and anything else becomes a generic internal error.
+func APIErrorFrom(err error) APIError {
+	var apiErr APIError
+
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.Is(err, database.ErrNotFound):
+		return NotFound("The requested resource could not be found")
+	case errors.Is(err, database.ErrConflict):
+		return Conflict("The request conflicts with the current state of the resource")
+	case errors.Is(err, database.ErrStorageFull):
+		return APIError{
+			Code:    "insufficient_storage",
+			Message: "The server is unable to store the data needed to complete your request",
+			Status:  http.StatusInsufficientStorage,
+		}
+	default:
+		return InternalError()
+	}
+}
+
+// WriteAPIError writes e as a JSON envelope with e.Status as the status code.
+func WriteAPIError(w http.ResponseWriter, e APIError) error {
+	if e.Status == 0 {
+		e.Status = http.StatusInternalServerError
+	}
+
+	return response.JSON(w, e.Status, map[string]APIError{"error": e})
+}
+
+// NotFoundHandler responds with a 404 not_found error envelope. It is intended
+// as the router's handler for unmatched paths, so missing routes get the same
+// error format as everything else.
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	WriteAPIError(w, NotFound("The requested resource could not be found"))
+}
+
+// MethodNotAllowedHandler responds with a 405 method_not_allowed error
+// envelope. It is intended as the router's handler for paths that match a
+// route but not its methods; the router sets the Allow header.
+func MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	WriteAPIError(w, MethodNotAllowed(fmt.Sprintf("The %s method is not supported for this resource", r.Method)))
+}
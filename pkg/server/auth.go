@@ -0,0 +1,38 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// RequireBearerToken rejects requests that don't carry token in an
+// "Authorization: Bearer <token>" header with a 401 unauthorized error. It is
+// meant for guarding operational endpoints, such as draining the server, that
+// must not be reachable by API clients. token must not be empty.
+func RequireBearerToken(token string) func(http.Handler) http.Handler {
+	if token == "" {
+		panic("server: RequireBearerToken with an empty token")
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, ok := bearerToken(r)
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				WriteAPIError(w, Unauthorized("A valid bearer token is required to access this resource"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"example.com/pkg/request"
+)
+
+// Validatable is implemented by request bodies that can check their own
+// values.
+type Validatable interface {
+	Validate() error
+}
+
+// FieldErrors maps field names to validation messages. Validate methods can
+// return it so BindJSON reports each problem against its field.
+type FieldErrors map[string]string
+
+func (fe FieldErrors) Error() string {
+	keys := make([]string, 0, len(fe))
+	for k := range fe {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	problems := make([]string, len(keys))
+	for i, k := range keys {
+		problems[i] = k + ": " + fe[k]
+	}
+	return strings.Join(problems, "; ")
+}
+
+// BindJSON decodes the request body into a T with request.DecodeJSON and calls
+// its Validate method. If either fails, it writes a 400 bad_request error and
+// returns false, in which case the handler should return without writing a
+// response:
+//
+//	input, ok := server.BindJSON[createUserInput](w, r)
+//	if !ok {
+//		return
+//	}
+//
+// A FieldErrors returned by Validate is included in the error details under
+// "fields"; any other error is used as the message.
+func BindJSON[T Validatable](w http.ResponseWriter, r *http.Request) (T, bool) {
+	var v T
+
+	if err := request.DecodeJSON(w, r, &v); err != nil {
+		WriteAPIError(w, BadRequest(err.Error()))
+		return v, false
+	}
+
+	if err := v.Validate(); err != nil {
+		var fields FieldErrors
+		if errors.As(err, &fields) {
+			e := BadRequest("The request body failed validation")
+			e.Details = map[string]any{"fields": fields}
+			WriteAPIError(w, e)
+		} else {
+			WriteAPIError(w, BadRequest(err.Error()))
+		}
+		return v, false
+	}
+
+	return v, true
+}
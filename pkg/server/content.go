@@ -0,0 +1,26 @@
+package server
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// WriteContent writes body with the given status and Content-Type, for
+// responses that aren't JSON. A "; charset=utf-8" suffix is added to text/*
+// types that don't name a charset, matching the JSON helpers.
+//
+// Content-Length isn't set, so a compression middleware further out can
+// still encode the body.
+func WriteContent(w http.ResponseWriter, status int, contentType string, body []byte) error {
+	if strings.HasPrefix(contentType, "text/") {
+		if _, params, err := mime.ParseMediaType(contentType); err == nil && params["charset"] == "" {
+			contentType += "; charset=utf-8"
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_, err := w.Write(body)
+	return err
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// RequireContentType rejects POST, PUT and PATCH requests whose Content-Type
+// isn't one of types with a 415 unsupported_media_type error. Media type
+// parameters such as charset are ignored and types are compared case
+// insensitively, so RequireContentType("application/json") accepts
+// "application/json; charset=utf-8".
+//
+// Requiring a JSON body stops browsers from submitting the API's forms from
+// another site, as an HTML form can't send application/json. Requests with
+// other methods, and requests with no body, such as a POST that only triggers
+// an action, are passed through.
+func RequireContentType(types ...string) func(http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(types))
+	for _, t := range types {
+		allowed[strings.ToLower(t)] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch:
+			default:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if r.ContentLength == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || !allowed[mediaType] {
+				WriteAPIError(w, APIError{
+					Code:    "unsupported_media_type",
+					Message: "Content-Type must be one of: " + strings.Join(types, ", "),
+					Status:  http.StatusUnsupportedMediaType,
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"example.com/pkg/database"
+	"example.com/pkg/leveledlog"
+)
+
+// RequestTimeoutHeader is read by DeadlineFromHeader.
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+// DeadlineFromHeader lets callers shorten the deadline of their request by
+// sending a duration such as "2s" in the X-Request-Timeout header. The request
+// context gets a deadline of the smaller of the requested timeout and max.
+// Requests without the header, or with a malformed or non-positive value, are
+// given a deadline of max.
+//
+// If the deadline expires, a warning is logged against the logger in the
+// request context, if any, saying whether it expired during a database query
+// (see database.QueryTracker) or elsewhere in the handler.
+//
+// Only apply this to routes reachable by trusted internal clients, since it
+// lets the client decide how much server time a request may use.
+func DeadlineFromHeader(max time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeout := max
+
+			if requested, err := time.ParseDuration(r.Header.Get(RequestTimeoutHeader)); err == nil && requested > 0 && requested < max {
+				timeout = requested
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			ctx, tracker := database.NewQueryTracker(ctx)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+
+			if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return
+			}
+			logger := leveledlog.FromContext(r.Context())
+			if logger == nil {
+				return
+			}
+			if query, ok := tracker.TimedOutQuery(); ok {
+				logger.WithFields(leveledlog.Fields{"query": query}).Warning("request timed out after %s during a database query", timeout)
+			} else {
+				logger.Warning("request timed out after %s in the handler", timeout)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Drain tracks whether the server is draining ahead of shutdown. While
+// draining, readiness checks should fail so load balancers stop sending new
+// traffic, but in-flight and new requests are still served.
+//
+// A server run by RunContext with the Drain in its Config shuts down once
+// draining starts, after the drain period, whether it was started by a
+// shutdown signal or by calling Start, e.g. from an admin endpoint.
+type Drain struct {
+	draining int32
+
+	once    sync.Once
+	mu      sync.Mutex
+	started chan struct{}
+}
+
+// Start puts the server into the draining state. It is safe to call more than
+// once.
+func (d *Drain) Start() {
+	atomic.StoreInt32(&d.draining, 1)
+	d.once.Do(func() {
+		close(d.startedChan())
+	})
+}
+
+// Draining reports whether Start has been called.
+func (d *Drain) Draining() bool {
+	return atomic.LoadInt32(&d.draining) == 1
+}
+
+// Started returns a channel that is closed when Start is first called.
+func (d *Drain) Started() <-chan struct{} {
+	return d.startedChan()
+}
+
+func (d *Drain) startedChan() chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.started == nil {
+		d.started = make(chan struct{})
+	}
+	return d.started
+}
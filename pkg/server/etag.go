@@ -0,0 +1,63 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"example.com/pkg/response"
+)
+
+// WriteJSONWithETag writes data as JSON like response.JSON, with a strong ETag
+// computed from the body. If the request is a GET or HEAD whose If-None-Match
+// header matches the ETag, or is "*", a 304 Not Modified is sent instead and
+// the body is skipped. Only 200 responses are made conditional.
+//
+// The whole body is marshalled into memory to hash it, so this isn't suited to
+// streamed or very large responses.
+func WriteJSONWithETag(w http.ResponseWriter, r *http.Request, status int, data any) error {
+	js, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	js = append(js, '\n')
+
+	sum := sha256.Sum256(js)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+
+	if status == http.StatusOK && (r.Method == http.MethodGet || r.Method == http.MethodHead) &&
+		etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", response.ContentTypeJSON)
+	w.WriteHeader(status)
+	w.Write(js)
+
+	return nil
+}
+
+// etagMatch reports whether the If-None-Match header value matches etag. It
+// uses the weak comparison required for If-None-Match, so W/"x" matches "x".
+func etagMatch(header, etag string) bool {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// RedirectHTTPSConfig configures RedirectHTTPS.
+type RedirectHTTPSConfig struct {
+	// TrustedProxies lists the IP addresses or CIDR ranges, such as
+	// "10.0.0.0/8", of the proxies whose X-Forwarded-Proto header is
+	// believed. For requests from anywhere else the scheme is taken from the
+	// connection itself.
+	TrustedProxies []string
+
+	// ExemptPaths are served over plain HTTP without a redirect, so health
+	// checks that probe the server directly keep working. Paths are matched
+	// exactly.
+	ExemptPaths []string
+}
+
+// RedirectHTTPS permanently redirects plain HTTP requests to the same URL with
+// the https scheme, using 308 so the method and body are preserved. It panics
+// if a trusted proxy can't be parsed, since that is a configuration error.
+func RedirectHTTPS(cfg RedirectHTTPSConfig) func(http.Handler) http.Handler {
+	var trusted []*net.IPNet
+	for _, p := range cfg.TrustedProxies {
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(p)
+		if err != nil {
+			panic(fmt.Sprintf("server: invalid trusted proxy %q: %s", p, err))
+		}
+		trusted = append(trusted, ipNet)
+	}
+
+	exempt := make(map[string]bool, len(cfg.ExemptPaths))
+	for _, p := range cfg.ExemptPaths {
+		exempt[p] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if exempt[r.URL.Path] || isHTTPS(r, trusted) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			http.Redirect(w, r, "https://"+r.Host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+		})
+	}
+}
+
+// isHTTPS reports whether the client connected over HTTPS, either directly or
+// to a trusted proxy.
+func isHTTPS(r *http.Request, trusted []*net.IPNet) bool {
+	if r.TLS != nil {
+		return true
+	}
+
+	ip := net.ParseIP(ClientIP(r))
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range trusted {
+		if ipNet.Contains(ip) {
+			return strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net"
+	"time"
+)
+
+// DefaultTCPKeepAlive is the keep-alive period used when Config.TCPKeepAlive
+// is zero.
+const DefaultTCPKeepAlive = 3 * time.Minute
+
+// keepAliveListener sets the TCP keep-alive period of each accepted
+// connection. It embeds *net.TCPListener so the listener can still be handed
+// over by a graceful restart.
+type keepAliveListener struct {
+	*net.TCPListener
+	period time.Duration
+}
+
+func (ln keepAliveListener) Accept() (net.Conn, error) {
+	conn, err := ln.AcceptTCP()
+	if err != nil {
+		return nil, err
+	}
+
+	if ln.period < 0 {
+		conn.SetKeepAlive(false)
+		return conn, nil
+	}
+
+	conn.SetKeepAlive(true)
+	conn.SetKeepAlivePeriod(ln.period)
+	return conn, nil
+}
+
+// withKeepAlive applies the keep-alive period to ln if it is a TCP listener.
+func withKeepAlive(ln net.Listener, period time.Duration) net.Listener {
+	tcp, ok := ln.(*net.TCPListener)
+	if !ok {
+		return ln
+	}
+	return keepAliveListener{TCPListener: tcp, period: period}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/pkg/leveledlog"
+)
+
+// panicSignatureFrames is how many stack frames identify a panic.
+const panicSignatureFrames = 3
+
+// panicSignature identifies the panic being recovered by the innermost frames
+// of the panicking stack, below the runtime's own. It must be called from the
+// deferred function that recovers. The panic value isn't included, as it
+// often holds per-request details such as an index.
+func panicSignature() string {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var sig []string
+	panicking := false
+	for len(sig) < panicSignatureFrames {
+		frame, more := frames.Next()
+		if frame.Function == "runtime.gopanic" {
+			panicking = true
+		} else if panicking && !strings.HasPrefix(frame.Function, "runtime.") {
+			sig = append(sig, fmt.Sprintf("%s:%d", frame.Function, frame.Line))
+		}
+		if !more {
+			break
+		}
+	}
+	return strings.Join(sig, " < ")
+}
+
+// panicLimiter allows up to limit panics with the same signature to be logged
+// per interval, and logs how many more were suppressed at the end of it.
+type panicLimiter struct {
+	logger   leveledlog.Interface
+	limit    int
+	interval time.Duration
+
+	mu      sync.Mutex
+	windows map[string]*panicWindow
+}
+
+type panicWindow struct {
+	start      time.Time
+	logged     int
+	suppressed int
+}
+
+func newPanicLimiter(logger leveledlog.Interface, limit int, interval time.Duration) *panicLimiter {
+	return &panicLimiter{
+		logger:   logger,
+		limit:    limit,
+		interval: interval,
+		windows:  make(map[string]*panicWindow),
+	}
+}
+
+// allow reports whether a panic with signature sig should be logged.
+func (pl *panicLimiter) allow(sig string) bool {
+	if pl.limit < 0 {
+		return true
+	}
+
+	now := time.Now()
+
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	win, ok := pl.windows[sig]
+	if !ok || now.Sub(win.start) >= pl.interval {
+		pl.sweep(now)
+		win = &panicWindow{start: now}
+		pl.windows[sig] = win
+	}
+
+	if win.logged < pl.limit {
+		win.logged++
+		return true
+	}
+
+	if win.suppressed == 0 {
+		time.AfterFunc(win.start.Add(pl.interval).Sub(now), func() {
+			pl.summarize(sig, win)
+		})
+	}
+	win.suppressed++
+	return false
+}
+
+// sweep forgets windows that have ended, so signatures that stopped panicking
+// don't accumulate. It must be called with mu held.
+func (pl *panicLimiter) sweep(now time.Time) {
+	for sig, win := range pl.windows {
+		if now.Sub(win.start) >= pl.interval {
+			delete(pl.windows, sig)
+		}
+	}
+}
+
+// summarize logs the number of panics suppressed in win once it has ended.
+func (pl *panicLimiter) summarize(sig string, win *panicWindow) {
+	pl.mu.Lock()
+	n := win.suppressed
+	pl.mu.Unlock()
+
+	pl.logger.WithFields(leveledlog.Fields{
+		"panic_signature": sig,
+		"suppressed":      n,
+	}).Warning("%d more panics with the same signature were suppressed in the last %s", n, pl.interval)
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// KeyFunc returns the key a request is rate limited by.
+type KeyFunc func(r *http.Request) string
+
+// ClientIP keys requests by the client IP address.
+func ClientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// RouteAndClientIP keys requests by route and client IP address, so a client's
+// usage of one route does not count towards its limit on another.
+func RouteAndClientIP(route string) KeyFunc {
+	return func(r *http.Request) string {
+		return route + " " + ClientIP(r)
+	}
+}
+
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// RateLimiter is a token bucket rate limiter. Each instance keeps its own
+// buckets, so independent limiters can be applied globally and to individual
+// routes with different parameters:
+//
+//	reports := server.NewRateLimiter(0.5, 2, server.RouteAndClientIP("/reports"))
+//	mux.Handle("/reports", reports.Middleware(http.HandlerFunc(app.reports)), "GET")
+type RateLimiter struct {
+	// Exceeded handles requests that are over the limit. It defaults to a 429
+	// rate_limited error envelope.
+	Exceeded http.Handler
+
+	rate  float64
+	burst float64
+	key   KeyFunc
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewRateLimiter returns a limiter that allows rate requests per second for
+// each key, with bursts of up to burst requests. If key is nil requests are
+// keyed by ClientIP.
+func NewRateLimiter(rate float64, burst int, key KeyFunc) *RateLimiter {
+	if key == nil {
+		key = ClientIP
+	}
+
+	return &RateLimiter{
+		Exceeded:  http.HandlerFunc(rateLimitExceeded),
+		rate:      rate,
+		burst:     float64(burst),
+		key:       key,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow reports whether a request for key is allowed, consuming a token if so.
+func (rl *RateLimiter) Allow(key string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	rl.sweep(now)
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{tokens: rl.burst}
+		rl.buckets[key] = b
+	} else {
+		b.tokens += now.Sub(b.lastSeen).Seconds() * rl.rate
+		if b.tokens > rl.burst {
+			b.tokens = rl.burst
+		}
+	}
+	b.lastSeen = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// sweep removes buckets that have been idle long enough to have refilled, so
+// the map doesn't grow without bound.
+func (rl *RateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < time.Minute {
+		return
+	}
+	rl.lastSweep = now
+
+	for key, b := range rl.buckets {
+		if now.Sub(b.lastSeen) > 3*time.Minute {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+// Middleware rejects requests that exceed the limit.
+func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !rl.Allow(rl.key(r)) {
+			rl.Exceeded.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func rateLimitExceeded(w http.ResponseWriter, r *http.Request) {
+	WriteAPIError(w, TooManyRequests())
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"example.com/pkg/leveledlog"
+)
+
+// crashWriter is implemented by *leveledlog.Logger.
+type crashWriter interface {
+	WriteCrash(message string, stack []byte) error
+}
+
+// RecovererConfig configures RecovererWithConfig.
+type RecovererConfig struct {
+	// PanicLogLimit is how many panics with the same signature, the
+	// innermost frames of the panicking stack, are logged per
+	// PanicLogInterval. Further panics within the interval are still
+	// recovered and answered with a 500, but are only counted, and the count
+	// is logged at the end of the interval. This stops a bug that makes every
+	// request panic from filling the log volume with identical stacks. They
+	// default to 5 per minute. A negative PanicLogLimit logs every panic.
+	PanicLogLimit    int
+	PanicLogInterval time.Duration
+}
+
+// Recoverer recovers panics in later handlers, logs the recovered value along
+// with the request method, path and ID, and responds with a generic 500 error.
+// The stack trace is included according to the logger's stack trace level.
+// Identical panics are rate-limited as described by RecovererConfig.
+//
+// If the logger has a crash file, see leveledlog.WithCrashFile, the panic and
+// its full stack are also written there.
+func Recoverer(logger leveledlog.Interface) func(http.Handler) http.Handler {
+	return RecovererWithConfig(logger, RecovererConfig{})
+}
+
+// RecovererWithConfig is like Recoverer, but rate-limits the logging of
+// identical panics according to cfg.
+func RecovererWithConfig(logger leveledlog.Interface, cfg RecovererConfig) func(http.Handler) http.Handler {
+	if cfg.PanicLogLimit == 0 {
+		cfg.PanicLogLimit = 5
+	}
+	if cfg.PanicLogInterval <= 0 {
+		cfg.PanicLogInterval = time.Minute
+	}
+
+	limiter := newPanicLimiter(logger, cfg.PanicLogLimit, cfg.PanicLogInterval)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if err := recover(); err != nil {
+					if limiter.allow(panicSignature()) {
+						if c, ok := logger.(crashWriter); ok {
+							c.WriteCrash(fmt.Sprintf("panic: %v", err), debug.Stack())
+						}
+
+						logger.WithFields(leveledlog.Fields{
+							"method":     r.Method,
+							"path":       r.URL.Path,
+							"request_id": RequestIDFromContext(r.Context()),
+						}).Error(fmt.Errorf("panic: %v", err))
+					}
+
+					w.Header().Set("Connection", "close")
+					WriteAPIError(w, InternalError())
+				}
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
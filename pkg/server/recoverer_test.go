@@ -0,0 +1,94 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example.com/pkg/leveledlog"
+)
+
+type spyEntry struct {
+	fields leveledlog.Fields
+	err    error
+}
+
+// spyLogger is an Interface implementation that records logged errors along
+// with the fields attached to the logger.
+type spyLogger struct {
+	fields  leveledlog.Fields
+	entries *[]spyEntry
+}
+
+func newSpyLogger() spyLogger {
+	return spyLogger{entries: &[]spyEntry{}}
+}
+
+func (s spyLogger) Enabled(leveledlog.Level) bool { return true }
+func (s spyLogger) Debug(string, ...any)          {}
+func (s spyLogger) Info(string, ...any)           {}
+func (s spyLogger) Warning(string, ...any)        {}
+func (s spyLogger) Fatal(err error)               { s.Error(err) }
+func (s spyLogger) FatalCode(_ int, err error)    { s.Error(err) }
+func (s spyLogger) LogError(err error) error      { s.Error(err); return err }
+func (s spyLogger) LogErrorf(format string, v ...any) error {
+	return s.LogError(fmt.Errorf(format, v...))
+}
+
+func (s spyLogger) Error(err error) {
+	*s.entries = append(*s.entries, spyEntry{fields: s.fields, err: err})
+}
+
+func (s spyLogger) WithFields(fields leveledlog.Fields) leveledlog.Interface {
+	merged := leveledlog.Fields{}
+	for k, v := range s.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return spyLogger{fields: merged, entries: s.entries}
+}
+
+func (s spyLogger) Named(component string) leveledlog.Interface {
+	return s.WithFields(leveledlog.Fields{"component": component})
+}
+
+func TestRecovererLogsRequestFields(t *testing.T) {
+	logger := newSpyLogger()
+
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(errors.New("boom"))
+	})
+	handler := RequestID(Recoverer(logger)(panicking))
+
+	req := httptest.NewRequest(http.MethodPost, "/widgets/1", nil)
+	req.Header.Set("X-Request-ID", "abc123")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d; want %d", rr.Code, http.StatusInternalServerError)
+	}
+
+	entries := *logger.entries
+	if len(entries) != 1 {
+		t.Fatalf("got %d log entries; want 1", len(entries))
+	}
+
+	want := leveledlog.Fields{
+		"method":     http.MethodPost,
+		"path":       "/widgets/1",
+		"request_id": "abc123",
+	}
+	for k, v := range want {
+		if got := entries[0].fields[k]; got != v {
+			t.Errorf("got %s %v; want %v", k, got, v)
+		}
+	}
+	if got := entries[0].err.Error(); got != "panic: boom" {
+		t.Errorf("got error %q; want %q", got, "panic: boom")
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+type contextKey string
+
+const requestIDContextKey = contextKey("requestID")
+
+// RequestIDHeader is the header used to read and return request IDs.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDConfig configures RequestIDWithConfig.
+type RequestIDConfig struct {
+	// IDGenerator returns a new request ID. It defaults to RandomID. Any
+	// scheme can be plugged in without this package depending on it, e.g. a
+	// ULID library for IDs that sort by time:
+	//
+	//	server.RequestIDConfig{IDGenerator: func() string { return ulid.Make().String() }}
+	IDGenerator func() string
+}
+
+// RequestID stores an ID for each request in the request context and returns
+// it in the X-Request-ID response header. An ID supplied by the client in the
+// same header is reused. New IDs are generated by RandomID.
+func RequestID(next http.Handler) http.Handler {
+	return RequestIDWithConfig(RequestIDConfig{})(next)
+}
+
+// RequestIDWithConfig is like RequestID, but generates new IDs with
+// cfg.IDGenerator.
+func RequestIDWithConfig(cfg RequestIDConfig) func(http.Handler) http.Handler {
+	if cfg.IDGenerator == nil {
+		cfg.IDGenerator = RandomID
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if id == "" {
+				id = cfg.IDGenerator()
+			}
+
+			w.Header().Set(RequestIDHeader, id)
+
+			ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequestIDFromContext returns the request ID stored by RequestID, or an empty
+// string if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+// RandomID returns 16 bytes from crypto/rand as 32 hex characters, the default
+// request ID scheme.
+func RandomID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
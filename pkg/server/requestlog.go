@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"example.com/pkg/database"
+	"example.com/pkg/leveledlog"
+)
+
+// RequestLoggerOption configures RequestLogger.
+type RequestLoggerOption func(*requestLoggerConfig)
+
+type requestLoggerConfig struct {
+	skipPaths      map[string]bool
+	queryThreshold int64
+	routes         *Routes
+}
+
+// WithSkipPaths stops RequestLogger logging successful requests to the given
+// paths, such as health checks polled every few seconds by an orchestrator.
+// Paths are matched exactly against the request path. Requests to them are
+// still served as normal, and are logged if they fail with a 4xx or 5xx
+// status or the client disconnects.
+func WithSkipPaths(paths ...string) RequestLoggerOption {
+	return func(cfg *requestLoggerConfig) {
+		if cfg.skipPaths == nil {
+			cfg.skipPaths = make(map[string]bool)
+		}
+		for _, p := range paths {
+			cfg.skipPaths[p] = true
+		}
+	}
+}
+
+// WithQueryWarningThreshold logs requests that run more than n database
+// queries at Warning level, to surface N+1 query patterns. Server errors are
+// still logged at Error.
+func WithQueryWarningThreshold(n int) RequestLoggerOption {
+	return func(cfg *requestLoggerConfig) {
+		cfg.queryThreshold = int64(n)
+	}
+}
+
+// WithRoutes sets the routes RequestLogger looks up the route field in. It
+// should be the Routes the router's patterns were registered with.
+func WithRoutes(routes *Routes) RequestLoggerOption {
+	return func(cfg *requestLoggerConfig) {
+		cfg.routes = routes
+	}
+}
+
+const logFieldsContextKey = contextKey("logFields")
+
+// logFields accumulates the fields added with AddLogField during a request.
+type logFields struct {
+	mu     sync.Mutex
+	fields leveledlog.Fields
+}
+
+// AddLogField adds a field to the entry RequestLogger writes once the request
+// completes, so middleware and handlers can enrich it, e.g. with the user or
+// tenant set by authentication middleware. It does nothing if ctx doesn't
+// come from a request handled by RequestLogger. Fields can't replace the
+// standard request fields.
+func AddLogField(ctx context.Context, key string, value any) {
+	lf, ok := ctx.Value(logFieldsContextKey).(*logFields)
+	if !ok {
+		return
+	}
+
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	lf.fields[key] = value
+}
+
+// StatusClientClosedRequest is the nginx convention for a request the client
+// abandoned before a response was sent.
+const StatusClientClosedRequest = 499
+
+// RequestLogger logs each request once it has completed, with its status,
+// size and duration. The route field holds the pattern registered with the
+// Routes set by WithRoutes that matched the request path, or the raw path if
+// none did or no Routes were set, and db_queries the number of queries run
+// through the database package with the request context, see
+// database.NewQueryCounter. Server errors are logged at Error level and
+// everything else at Info.
+//
+// A child logger tagged with the request ID is stored in the request context,
+// see leveledlog.FromContext, so code further down the stack can log against
+// the request.
+//
+// If the client disconnected before the handler finished, the entry is logged
+// at Info with client_disconnected=true and status 499, so client hangups can
+// be told apart from real server errors.
+func RequestLogger(logger leveledlog.Interface, opts ...RequestLoggerOption) func(http.Handler) http.Handler {
+	var cfg requestLoggerConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := newResponseRecorder(w)
+
+			reqLogger := logger.WithFields(leveledlog.Fields{"request_id": RequestIDFromContext(r.Context())})
+			extra := &logFields{fields: leveledlog.Fields{}}
+			ctx := leveledlog.NewContext(r.Context(), reqLogger)
+			ctx, queries := database.NewQueryCounter(ctx)
+			r = r.WithContext(context.WithValue(ctx, logFieldsContextKey, extra))
+
+			next.ServeHTTP(rec, r)
+
+			// Log the matched route pattern too, which unlike the URI can be
+			// grouped by endpoint.
+			route := r.URL.Path
+			if cfg.routes != nil {
+				if pattern, ok := cfg.routes.Match(r.URL.Path); ok {
+					route = pattern
+				}
+			}
+
+			fields := leveledlog.Fields{
+				"method":     r.Method,
+				"uri":        r.URL.RequestURI(),
+				"route":      route,
+				"status":     rec.status,
+				"bytes":      rec.bytes,
+				"duration":   time.Since(start).String(),
+				"request_id": RequestIDFromContext(r.Context()),
+				"db_queries": queries.Count(),
+			}
+
+			extra.mu.Lock()
+			for k, v := range extra.fields {
+				if _, ok := fields[k]; !ok {
+					fields[k] = v
+				}
+			}
+			extra.mu.Unlock()
+
+			disconnected := errors.Is(r.Context().Err(), context.Canceled)
+			if cfg.skipPaths[r.URL.Path] && rec.status < http.StatusBadRequest && !disconnected {
+				return
+			}
+
+			if disconnected {
+				fields["status"] = StatusClientClosedRequest
+				fields["client_disconnected"] = true
+			}
+
+			l := logger.WithFields(fields)
+			message := fmt.Sprintf("%s %s", r.Method, r.URL.RequestURI())
+
+			switch {
+			case rec.status >= http.StatusInternalServerError && !disconnected:
+				l.Error(errors.New(message))
+			case cfg.queryThreshold > 0 && queries.Count() > cfg.queryThreshold:
+				l.Warning("%s: %d database queries, more than %d", message, queries.Count(), cfg.queryThreshold)
+			default:
+				l.Info("%s", message)
+			}
+		})
+	}
+}
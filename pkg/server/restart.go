@@ -0,0 +1,86 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+)
+
+// restartFDEnv tells a process started by a graceful restart which file
+// descriptor holds the inherited listener.
+const restartFDEnv = "SERVER_RESTART_FD"
+
+// notifyRestart returns a channel that receives the restart signal, or a nil
+// channel if graceful restart isn't enabled or supported.
+func notifyRestart(enabled bool) (<-chan os.Signal, func()) {
+	if !enabled || len(restartSignals) == 0 {
+		return nil, func() {}
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, restartSignals...)
+	return c, func() { signal.Stop(c) }
+}
+
+type filer interface {
+	File() (*os.File, error)
+}
+
+// startChild starts a copy of the running process that inherits ln.
+func startChild(ln net.Listener) error {
+	fl, ok := ln.(filer)
+	if !ok {
+		return fmt.Errorf("listener %T can't be inherited", ln)
+	}
+
+	// The child takes over the socket file, so the parent mustn't remove it
+	// when it closes its listener.
+	if ul, ok := ln.(*net.UnixListener); ok {
+		ul.SetUnlinkOnClose(false)
+	}
+
+	f, err := fl.File()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	path, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{f}
+	cmd.Env = append(os.Environ(), restartFDEnv+"="+strconv.Itoa(listenFDsStart))
+
+	return cmd.Start()
+}
+
+// inheritedListener returns the listener passed down by a graceful restart,
+// or nil if the process wasn't started by one.
+func inheritedListener() (net.Listener, error) {
+	value, ok := os.LookupEnv(restartFDEnv)
+	if !ok {
+		return nil, nil
+	}
+	// Don't pass the variable on to a later restart's child.
+	os.Unsetenv(restartFDEnv)
+
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, errors.New("invalid " + restartFDEnv + ": " + value)
+	}
+
+	f := os.NewFile(uintptr(fd), "inherited-listener")
+	defer f.Close()
+
+	return net.FileListener(f)
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package server
+
+import (
+	"os"
+	"syscall"
+)
+
+var restartSignals = []os.Signal{syscall.SIGUSR2}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"example.com/pkg/leveledlog"
+)
+
+// Retry calls fn up to attempts times until it succeeds, waiting backoff
+// before the second attempt and doubling the wait after each failure. It
+// returns the last error from fn, or the context error if ctx is cancelled
+// while waiting. fn is always called at least once, even if attempts is less
+// than 1.
+//
+// Each failed attempt is logged at Warning level to the logger stored in ctx,
+// if there is one (see leveledlog.NewContext).
+func Retry(ctx context.Context, attempts int, backoff time.Duration, fn func() error) error {
+	logger := leveledlog.FromContext(ctx)
+
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = fn()
+		if err == nil {
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+
+		if logger != nil {
+			logger.Warning("attempt %d of %d failed, retrying in %s: %s", attempt, attempts, backoff, err)
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		backoff *= 2
+	}
+	return err
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRetryAttempts(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     int
+	}{
+		{attempts: -1, want: 1},
+		{attempts: 0, want: 1},
+		{attempts: 1, want: 1},
+		{attempts: 3, want: 3},
+	}
+
+	for _, tt := range tests {
+		calls := 0
+		err := Retry(context.Background(), tt.attempts, 0, func() error {
+			calls++
+			return errors.New("failed")
+		})
+
+		if err == nil {
+			t.Errorf("attempts %d: got nil error; want the last error from fn", tt.attempts)
+		}
+		if calls != tt.want {
+			t.Errorf("attempts %d: got %d calls; want %d", tt.attempts, calls, tt.want)
+		}
+	}
+}
@@ -3,39 +3,368 @@ package server
 import (
 	"context"
 	"errors"
+	"io/fs"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
+
+	"example.com/pkg/leveledlog"
 )
 
+// DefaultShutdownTimeouts returns the default shutdown timeouts. SIGTERM, sent
+// by orchestrators for a normal shutdown, gets a longer grace period to drain
+// in-flight requests than SIGINT, which is usually a developer pressing
+// Ctrl-C. Each call returns a new map, which the caller may modify.
+func DefaultShutdownTimeouts() map[os.Signal]time.Duration {
+	return map[os.Signal]time.Duration{
+		syscall.SIGTERM: 20 * time.Second,
+		syscall.SIGINT:  5 * time.Second,
+	}
+}
+
+type Config struct {
+	// Addr is a TCP address such as "localhost:4444", or a Unix socket path
+	// prefixed with "unix:", e.g. "unix:/run/app.sock".
+	Addr string
+
+	// Listener, if set, is used instead of binding Addr. This allows any
+	// net.Listener to be used, such as one inherited from systemd.
+	Listener net.Listener
+
+	// SocketMode sets the file permissions of a Unix socket created for Addr.
+	// Zero leaves the permissions determined by the umask.
+	SocketMode os.FileMode
+
+	// ReadTimeout, WriteTimeout and IdleTimeout are passed to the
+	// http.Server. They default to 10s, 30s and 1m.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+
+	// MaxHeaderBytes caps the size of the request line and headers a client
+	// may send, passed to the http.Server. It defaults to 1MB. Request bodies
+	// aren't covered and are limited separately where they are read, e.g. by
+	// request.DecodeJSON.
+	MaxHeaderBytes int
+
+	// TCPKeepAlive is the period between TCP keep-alive probes on accepted
+	// connections, so connections to clients that disappeared, e.g. behind a
+	// NAT that dropped its mapping, are closed rather than held open. It
+	// defaults to DefaultTCPKeepAlive and a negative value disables
+	// keep-alives. It applies to TCP listeners only, including one passed in
+	// Listener.
+	TCPKeepAlive time.Duration
+
+	// ShutdownTimeouts maps each signal that triggers a graceful shutdown to
+	// how long in-flight requests are given to complete. Defaults to
+	// DefaultShutdownTimeouts when nil or empty.
+	ShutdownTimeouts map[os.Signal]time.Duration
+
+	// Drain, if set, is put into the draining state when a shutdown signal
+	// other than SIGINT is received, and the server shuts down once it is put
+	// into the draining state by anything else. Either way the server then
+	// keeps serving for DrainPeriod, giving load balancers time to notice the
+	// failing readiness check, before shutting down. SIGINT, usually a
+	// developer pressing Ctrl-C, shuts down without waiting.
+	Drain       *Drain
+	DrainPeriod time.Duration
+
+	// ReadyFile, if set, is created once the server is listening and removed
+	// as soon as shutdown begins, for orchestrators that probe readiness with
+	// a file check such as "test -f /tmp/ready".
+	ReadyFile string
+
+	// GracefulRestart makes the server restart the process without dropping
+	// connections when it receives SIGUSR2. The running binary is started
+	// again with the same arguments and environment, inheriting the listening
+	// socket, and the old process then shuts down gracefully as it would on
+	// SIGTERM, including any drain period, while the new one accepts new
+	// connections on the same socket.
+	//
+	// This suits a single host with no load balancer in front. The new
+	// process is a child of the old one and is reparented when the old one
+	// exits, so under a supervisor such as systemd the main PID changes: use
+	// Type=simple with NotifyAccess=all, or a supervisor that expects it. The
+	// new process must start up within the old one's shutdown timeout to
+	// avoid a gap in service. On Windows, which has no SIGUSR2 or descriptor
+	// inheritance, it does nothing.
+	GracefulRestart bool
+
+	// Logger is optional.
+	Logger *leveledlog.Logger
+}
+
 func Run(addr string, h http.Handler) error {
+	return RunWithConfig(Config{Addr: addr}, h)
+}
+
+// RunListener serves h on ln until a shutdown signal is received.
+func RunListener(ln net.Listener, h http.Handler) error {
+	return RunWithConfig(Config{Listener: ln}, h)
+}
+
+func RunWithConfig(cfg Config, h http.Handler) error {
+	return RunContext(context.Background(), cfg, h)
+}
+
+// RunContext is like RunWithConfig, but also shuts the server down when ctx is
+// cancelled, using the SIGTERM shutdown timeout.
+func RunContext(ctx context.Context, cfg Config, h http.Handler) error {
+	// An empty map would make signal.Notify relay every signal.
+	if len(cfg.ShutdownTimeouts) == 0 {
+		cfg.ShutdownTimeouts = DefaultShutdownTimeouts()
+	}
+
+	if cfg.ReadTimeout == 0 {
+		cfg.ReadTimeout = 10 * time.Second
+	}
+	if cfg.WriteTimeout == 0 {
+		cfg.WriteTimeout = 30 * time.Second
+	}
+	if cfg.IdleTimeout == 0 {
+		cfg.IdleTimeout = time.Minute
+	}
+	if cfg.MaxHeaderBytes == 0 {
+		cfg.MaxHeaderBytes = 1 << 20
+	}
+	if cfg.TCPKeepAlive == 0 {
+		cfg.TCPKeepAlive = DefaultTCPKeepAlive
+	}
+
+	shuttingDown, startShutdown := context.WithCancel(context.Background())
+	defer startShutdown()
+
 	srv := &http.Server{
-		Addr:         addr,
-		Handler:      h,
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 30 * time.Second,
+		Addr:           cfg.Addr,
+		Handler:        h,
+		IdleTimeout:    cfg.IdleTimeout,
+		ReadTimeout:    cfg.ReadTimeout,
+		WriteTimeout:   cfg.WriteTimeout,
+		MaxHeaderBytes: cfg.MaxHeaderBytes,
+		BaseContext: func(net.Listener) context.Context {
+			return context.WithValue(context.Background(), shutdownContextKey, shuttingDown)
+		},
+	}
+
+	ln := cfg.Listener
+	if ln == nil {
+		var err error
+		ln, err = inheritedListener()
+		if err != nil {
+			return err
+		}
 	}
+	if ln == nil {
+		var err error
+		ln, err = listen(cfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	ln = withKeepAlive(ln, cfg.TCPKeepAlive)
 
-	shutdownError := make(chan error)
+	shutdownDone := make(chan shutdownResult, 1)
 
 	go func() {
+		signals := make([]os.Signal, 0, len(cfg.ShutdownTimeouts))
+		for sig := range cfg.ShutdownTimeouts {
+			signals = append(signals, sig)
+		}
+
 		quit := make(chan os.Signal, 1)
-		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-		<-quit
+		signal.Notify(quit, signals...)
+		defer signal.Stop(quit)
+
+		restart, stopRestart := notifyRestart(cfg.GracefulRestart)
+		defer stopRestart()
+
+		termTimeout := cfg.ShutdownTimeouts[syscall.SIGTERM]
+		if termTimeout == 0 {
+			termTimeout = DefaultShutdownTimeouts()[syscall.SIGTERM]
+		}
+
+		var drainStarted <-chan struct{}
+		if cfg.Drain != nil {
+			drainStarted = cfg.Drain.Started()
+		}
+
+		var reason string
+		var timeout time.Duration
+		drain := true
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		// A process started by a graceful restart takes over the ready file.
+		keepReadyFile := false
+
+	wait:
+		for {
+			select {
+			case sig := <-quit:
+				reason = sig.String()
+				timeout = cfg.ShutdownTimeouts[sig]
+				drain = sig != os.Interrupt
+				break wait
+			case <-drainStarted:
+				reason = "drain requested"
+				timeout = termTimeout
+				break wait
+			case sig := <-restart:
+				if err := startChild(ln); err != nil {
+					cfg.warning("%s, unable to restart: %s", sig, err)
+					continue
+				}
+				reason = sig.String() + ", restarted"
+				keepReadyFile = true
+				timeout = termTimeout
+				break wait
+			case <-ctx.Done():
+				reason = "context cancelled"
+				timeout = termTimeout
+				break wait
+			}
+		}
+
+		startShutdown()
+		if !keepReadyFile {
+			cfg.removeReadyFile()
+		}
+
+		if cfg.Drain != nil && drain {
+			cfg.Drain.Start()
+			if cfg.DrainPeriod > 0 {
+				cfg.info("%s, draining for %s", reason, cfg.DrainPeriod)
+				time.Sleep(cfg.DrainPeriod)
+			}
+		}
+
+		cfg.info("%s, shutting down with %s timeout", reason, timeout)
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
 		defer cancel()
 
-		shutdownError <- srv.Shutdown(ctx)
+		shutdownDone <- shutdownResult{err: srv.Shutdown(ctx), keepReadyFile: keepReadyFile}
 	}()
 
-	err := srv.ListenAndServe()
+	// Only set from the shutdown result, so the goroutine above never shares
+	// it.
+	keepReadyFile := false
+
+	// Log the bound address once listening, which includes the port chosen
+	// by the OS for ":0".
+	cfg.info("listening on %s", ln.Addr())
+
+	if cfg.ReadyFile != "" {
+		if err := os.WriteFile(cfg.ReadyFile, nil, 0o644); err != nil {
+			cfg.warning("unable to create ready file: %s", err)
+		}
+		defer func() {
+			if !keepReadyFile {
+				cfg.removeReadyFile()
+			}
+		}()
+	}
+
+	// Tell systemd we're ready when running as a Type=notify service.
+	if err := NotifySystemd("READY=1"); err != nil {
+		cfg.warning("unable to notify systemd: %s", err)
+	}
+
+	// Serve closes the listener on shutdown, which also removes the socket
+	// file of a Unix listener.
+	err := srv.Serve(ln)
 	if !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
 
-	return <-shutdownError
+	result := <-shutdownDone
+	keepReadyFile = result.keepReadyFile
+	if result.err != nil {
+		return result.err
+	}
+
+	cfg.info("server stopped")
+
+	return nil
+}
+
+// shutdownResult is sent by the goroutine that shuts the server down once it
+// has finished.
+type shutdownResult struct {
+	err           error
+	keepReadyFile bool
+}
+
+const shutdownContextKey = contextKey("shutdown")
+
+// ShutdownContext returns a context that is cancelled as soon as the server
+// serving r begins a graceful shutdown, before any drain period. Long-polling
+// handlers can select on it to return promptly instead of holding up the
+// shutdown:
+//
+//	select {
+//	case event := <-events:
+//		...
+//	case <-server.ShutdownContext(r).Done():
+//		w.WriteHeader(http.StatusNoContent)
+//	case <-r.Context().Done():
+//	}
+//
+// For requests not served by RunContext, the returned context is never
+// cancelled.
+func ShutdownContext(r *http.Request) context.Context {
+	if ctx, ok := r.Context().Value(shutdownContextKey).(context.Context); ok {
+		return ctx
+	}
+	return context.Background()
+}
+
+func (cfg Config) removeReadyFile() {
+	if cfg.ReadyFile == "" {
+		return
+	}
+	if err := os.Remove(cfg.ReadyFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		cfg.warning("unable to remove ready file: %s", err)
+	}
+}
+
+func (cfg Config) info(format string, v ...any) {
+	if cfg.Logger != nil {
+		cfg.Logger.Info(format, v...)
+	}
+}
+
+func (cfg Config) warning(format string, v ...any) {
+	if cfg.Logger != nil {
+		cfg.Logger.Warning(format, v...)
+	}
+}
+
+func listen(cfg Config) (net.Listener, error) {
+	if !strings.HasPrefix(cfg.Addr, "unix:") {
+		return net.Listen("tcp", cfg.Addr)
+	}
+	path := strings.TrimPrefix(cfg.Addr, "unix:")
+
+	// Remove a socket file left behind by an unclean shutdown.
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.SocketMode != 0 {
+		if err := os.Chmod(path, cfg.SocketMode); err != nil {
+			ln.Close()
+			return nil, err
+		}
+	}
+
+	return ln, nil
 }
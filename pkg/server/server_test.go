@@ -0,0 +1,39 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDrainStartShutsDown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	drain := &Drain{}
+	cfg := Config{
+		Listener:    ln,
+		Drain:       drain,
+		DrainPeriod: 50 * time.Millisecond,
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- RunContext(context.Background(), cfg, http.NotFoundHandler())
+	}()
+
+	drain.Start()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("got error %v; want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server didn't shut down after draining started")
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd socket
+// activation, following stdin, stdout and stderr.
+const listenFDsStart = 3
+
+// ListenerFromSystemd returns the listener passed to the process by systemd
+// socket activation. The bool result is false if the process was not socket
+// activated, in which case the caller should bind its own listener.
+func ListenerFromSystemd() (net.Listener, bool, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, false, nil
+	}
+
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds < 1 {
+		return nil, false, nil
+	}
+	if fds > 1 {
+		return nil, false, fmt.Errorf("systemd passed %d sockets, expected 1", fds)
+	}
+
+	// Unset the variables so they are not inherited by child processes.
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	f := os.NewFile(uintptr(listenFDsStart), "systemd-socket")
+	defer f.Close()
+
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return ln, true, nil
+}
+
+// NotifySystemd sends state, such as "READY=1", to the service manager via
+// NOTIFY_SOCKET. It does nothing if NOTIFY_SOCKET is not set.
+func NotifySystemd(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+
+	// A leading '@' denotes a socket in the abstract namespace.
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return errors.New("systemd notify: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Routes is a registry of named route patterns, for building URLs with URLFor
+// and for looking up the pattern a request matched with Match. Each router
+// owns its own, so several routers, e.g. a public and an internal one, don't
+// share names. The zero value is not usable; create one with NewRoutes.
+type Routes struct {
+	mu     sync.RWMutex
+	byName map[string]string
+
+	// patterns holds the registered patterns in registration order, which is
+	// the order flow tries them in.
+	patterns []*routePattern
+}
+
+// NewRoutes returns an empty Routes.
+func NewRoutes() *Routes {
+	return &Routes{byName: make(map[string]string)}
+}
+
+// routePattern is a registered pattern split into segments, with the regular
+// expressions of its parameters compiled once when it is registered rather
+// than on every match.
+type routePattern struct {
+	pattern  string
+	segments []string
+	regexps  []*regexp.Regexp
+}
+
+// compilePattern splits pattern into segments and compiles the regular
+// expressions of its "|"-constrained parameters. A parameter whose regular
+// expression doesn't compile never matches, as in flow.
+func compilePattern(pattern string) *routePattern {
+	p := &routePattern{pattern: pattern, segments: strings.Split(pattern, "/")}
+	p.regexps = make([]*regexp.Regexp, len(p.segments))
+
+	for i, segment := range p.segments {
+		if !strings.HasPrefix(segment, ":") {
+			continue
+		}
+		if pipe := strings.Index(segment, "|"); pipe != -1 {
+			if rx, err := regexp.Compile(segment[pipe+1:]); err == nil {
+				p.regexps[i] = rx
+			}
+		}
+	}
+	return p
+}
+
+// Route registers pattern under name for use with URLFor and returns the
+// pattern, so it can be used inline when registering a handler:
+//
+//	mux.HandleFunc(urls.Route("user", "/users/:id"), app.showUser, "GET")
+func (rt *Routes) Route(name, pattern string) string {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if _, ok := rt.byName[name]; !ok {
+		rt.patterns = append(rt.patterns, compilePattern(pattern))
+	}
+	rt.byName[name] = pattern
+	return pattern
+}
+
+// Match returns the first registered pattern that matches path, using the
+// same rules as the flow router, e.g. "/users/:id" for "/users/12345".
+func (rt *Routes) Match(path string) (string, bool) {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+
+	pathSegments := strings.Split(path, "/")
+	for _, p := range rt.patterns {
+		if p.match(pathSegments) {
+			return p.pattern, true
+		}
+	}
+	return "", false
+}
+
+func (p *routePattern) match(pathSegments []string) bool {
+	if !strings.HasSuffix(p.pattern, "/...") && len(p.segments) != len(pathSegments) {
+		return false
+	}
+
+	for i, segment := range p.segments {
+		if i > len(pathSegments)-1 {
+			return false
+		}
+
+		if segment == "..." {
+			return true
+		}
+
+		if strings.HasPrefix(segment, ":") {
+			if !strings.Contains(segment, "|") {
+				if pathSegments[i] == "" {
+					return false
+				}
+				continue
+			}
+
+			rx := p.regexps[i]
+			if rx == nil || !rx.MatchString(pathSegments[i]) {
+				return false
+			}
+			continue
+		}
+
+		if pathSegments[i] != segment {
+			return false
+		}
+	}
+
+	return true
+}
+
+// URLFor builds the path of the route registered under name, substituting
+// params in order for the pattern's named parameters. For the example above,
+// urls.URLFor("user", 42) returns "/users/42".
+func (rt *Routes) URLFor(name string, params ...any) (string, error) {
+	rt.mu.RLock()
+	pattern, ok := rt.byName[name]
+	rt.mu.RUnlock()
+
+	if !ok {
+		return "", fmt.Errorf("no route named %q", name)
+	}
+
+	segments := strings.Split(pattern, "/")
+	used := 0
+
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, ":") {
+			continue
+		}
+		if used == len(params) {
+			return "", fmt.Errorf("route %q: missing value for parameter %s", name, segment)
+		}
+		segments[i] = url.PathEscape(fmt.Sprint(params[used]))
+		used++
+	}
+
+	if used != len(params) {
+		return "", fmt.Errorf("route %q: got %d parameters, want %d", name, len(params), used)
+	}
+
+	return strings.Join(segments, "/"), nil
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRoutePatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/users/:id", "/users/42", true},
+		{"/users/:id", "/users/", false},
+		{"/users/:id", "/users/42/posts", false},
+		{"/users/:id|^[0-9]+$", "/users/42", true},
+		{"/users/:id|^[0-9]+$", "/users/alice", false},
+		{"/users/:id|[", "/users/42", false},
+		{"/static/...", "/static/css/site.css", true},
+		{"/status", "/status", true},
+		{"/status", "/readyz", false},
+	}
+
+	for _, tt := range tests {
+		p := compilePattern(tt.pattern)
+		if got := p.match(strings.Split(tt.path, "/")); got != tt.want {
+			t.Errorf("%s against %s: got %t; want %t", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func BenchmarkRoutePatternMatch(b *testing.B) {
+	p := compilePattern("/users/:id|^[0-9]+$/posts/:post|^[a-z-]+$")
+	path := strings.Split("/users/42/posts/hello-world", "/")
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p.match(path)
+	}
+}
+
+func TestRoutesAreIndependent(t *testing.T) {
+	public := NewRoutes()
+	internal := NewRoutes()
+
+	public.Route("user", "/users/:id")
+	internal.Route("user", "/admin/users/:id")
+
+	got, err := public.URLFor("user", 42)
+	if err != nil || got != "/users/42" {
+		t.Errorf("public: got %q, %v; want /users/42", got, err)
+	}
+	got, err = internal.URLFor("user", 42)
+	if err != nil || got != "/admin/users/42" {
+		t.Errorf("internal: got %q, %v; want /admin/users/42", got, err)
+	}
+
+	if _, ok := public.Match("/admin/users/42"); ok {
+		t.Error("public matched an internal route")
+	}
+	if pattern, ok := internal.Match("/admin/users/42"); !ok || pattern != "/admin/users/:id" {
+		t.Errorf("internal: got %q, %t; want /admin/users/:id", pattern, ok)
+	}
+}
+
+func TestURLFor(t *testing.T) {
+	rt := NewRoutes()
+	rt.Route("post", "/users/:id/posts/:slug")
+
+	got, err := rt.URLFor("post", 42, "hello world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/users/42/posts/hello%20world"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	if _, err := rt.URLFor("post", 42); err == nil {
+		t.Error("missing parameter: got nil error")
+	}
+	if _, err := rt.URLFor("missing"); err == nil {
+		t.Error("unknown route: got nil error")
+	}
+}
@@ -0,0 +1,57 @@
+// Package testutil provides helpers for end-to-end tests of the HTTP and
+// database stack.
+package testutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"example.com/pkg/database"
+)
+
+// NewTestServer opens a database in a temporary directory, runs the
+// migrations, builds a handler from it with routes and starts an
+// *httptest.Server serving that handler. The server and database are torn
+// down when the test finishes.
+//
+// A minimal test of the status endpoint, from package main of cmd/api:
+//
+//	func TestStatus(t *testing.T) {
+//		ts, client := testutil.NewTestServer(t, func(db *database.Sqlite) http.Handler {
+//			app := &application{db: db, drain: &server.Drain{}, logger: leveledlog.NewLogger(io.Discard, leveledlog.LevelOff, false)}
+//			return app.routes()
+//		})
+//
+//		res, err := client.Get(ts.URL + "/status")
+//		if err != nil {
+//			t.Fatal(err)
+//		}
+//		defer res.Body.Close()
+//
+//		if res.StatusCode != http.StatusOK {
+//			t.Errorf("got status %d; want %d", res.StatusCode, http.StatusOK)
+//		}
+//	}
+func NewTestServer(t testing.TB, routes func(db *database.Sqlite) http.Handler, opts ...database.Option) (*httptest.Server, *http.Client) {
+	t.Helper()
+
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(routes(db))
+
+	// Cleanups run in reverse order, so the server stops before the
+	// database is closed.
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Cleanup(ts.Close)
+
+	return ts, ts.Client()
+}